@@ -51,9 +51,13 @@ type MasterConfig struct {
 
 // worker相关的配置
 type WorkerConfig struct {
-	Http       *HttpConfig     `json:"http" yaml:"http"`
-	MasterUrl  string          `json:"master_url" yaml:"master_url"`
-	Categories map[string]bool `json:"categories" yaml: "categories"`
+	Http                *HttpConfig     `json:"http" yaml:"http"`
+	MasterUrl           string          `json:"master_url" yaml:"master_url"`
+	Categories          map[string]bool `json:"categories" yaml: "categories"`
+	MaxConcurrency      int             `json:"max_concurrency" yaml:"max_concurrency"`           // 同时执行的任务数上限，默认是0不限制
+	CategoryConcurrency map[string]int  `json:"category_concurrency" yaml:"category_concurrency"` // 每个分类同时执行的任务数上限，未配置的不限制
+	RedactPatterns      []string        `json:"redact_patterns" yaml:"redact_patterns"`           // 执行结果脱敏的正则，追加到默认规则后面
+	TmpDir              string          `json:"tmp_dir" yaml:"tmp_dir"`                           // 任务临时目录的父目录，为空是系统临时目录下的cronjob
 }
 
 // Master Worker相关的配置
@@ -13,45 +13,63 @@ import (
 // */30 * * * * echo `date` >> /var/log/test.log
 type Job struct {
 	BaseFields
-	EtcdKey     string    `gorm:"size:100" json:"etcd_key, omitempty"`   // ETCD中保存的key
-	Category    *Category `gorm:"ForeignKey:CategoryID" json:"category"` // Job的分类
-	CategoryID  uint      `gorm:"INDEX;NOT NULL" json:"category_id"`     // 分类的ID
-	Name        string    `gorm:"size:256" json:"name"`                  // 任务的名称
-	Time        string    `gorm:"size:100;NOT NULL" json:"time"`         // 计划任务的时间
-	Command     string    `gorm:"size:256;NOT NULL" json:"command"`      // 任务的命令
-	Description string    `gorm:"size:512" json:"description,omitempty"` // Job描述
-	IsActive    bool      `gorm:"type:boolean" json:"is_active"`         // 是否激活，激活才执行
-	SaveOutput  bool      `gorm:"type:boolean" json:"save_output"`       // 是否记录输出
-	Timeout     int       `json:"timeout"`                               // 超时时间，默认是0不超时，单位为秒
+	EtcdKey        string    `gorm:"size:100" json:"etcd_key, omitempty"`   // ETCD中保存的key
+	Category       *Category `gorm:"ForeignKey:CategoryID" json:"category"` // Job的分类
+	CategoryID     uint      `gorm:"INDEX;NOT NULL" json:"category_id"`     // 分类的ID
+	Name           string    `gorm:"size:256" json:"name"`                  // 任务的名称
+	Time           string    `gorm:"size:100;NOT NULL" json:"time"`         // 计划任务的时间
+	Command        string    `gorm:"size:256;NOT NULL" json:"command"`      // 任务的命令
+	Description    string    `gorm:"size:512" json:"description,omitempty"` // Job描述
+	IsActive       bool      `gorm:"type:boolean" json:"is_active"`         // 是否激活，激活才执行
+	SaveOutput     bool      `gorm:"type:boolean" json:"save_output"`       // 是否记录输出
+	Timeout        int       `json:"timeout"`                               // 超时时间，默认是0不超时，单位为秒
+	MaxMemory      int       `json:"max_memory"`                            // 最大内存(MB)，默认是0不限制，超过会被杀掉
+	MaxProcs       int       `json:"max_procs"`                             // 最大进程数(ulimit -u)，默认是0不限制，worker以root运行时不生效
+	TmpQuota       int       `json:"tmp_quota"`                             // 临时目录配额(MB)，默认是0不限制，超过会被杀掉
+	Priority       int       `json:"priority"`                              // 优先级，越大越先执行，默认是0
+	OutputEncoding string    `gorm:"size:40" json:"output_encoding"`        // 命令输出的字符集，比如gbk，为空是utf-8
+	ExitCodeMap    string    `gorm:"size:256" json:"exit_code_map"`         // 退出码映射，比如：2:success,3:changed，默认0是成功，其它是失败
 }
 
 // 保存去Eetcd中的
 type JobEtcd struct {
-	ID          uint      `json:"id"`
-	CreatedAt   time.Time `json:"created_at"`
-	Category    string    `json:"category"`
-	Name        string    `json:"name"`
-	Time        string    `json:"time"`
-	Command     string    `json:"command"`
-	Description string    `json:"description"`
-	IsActive    bool      `json:"is_active"`
-	SaveOutput  bool      `json:"save_output"`
-	Timeout     int       `json:"timeout"`
+	ID             uint      `json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	Category       string    `json:"category"`
+	Name           string    `json:"name"`
+	Time           string    `json:"time"`
+	Command        string    `json:"command"`
+	Description    string    `json:"description"`
+	IsActive       bool      `json:"is_active"`
+	SaveOutput     bool      `json:"save_output"`
+	Timeout        int       `json:"timeout"`
+	MaxMemory      int       `json:"max_memory"`
+	MaxProcs       int       `json:"max_procs"`
+	TmpQuota       int       `json:"tmp_quota"`
+	Priority       int       `json:"priority"`
+	OutputEncoding string    `json:"output_encoding"`
+	ExitCodeMap    string    `json:"exit_code_map"`
 }
 
 // Job To JobEtcd
 func (job *Job) ToEtcdStruct() *JobEtcd {
 	return &JobEtcd{
-		ID:          job.ID,
-		CreatedAt:   job.CreatedAt,
-		Category:    job.Category.Name,
-		Name:        job.Name,
-		Time:        job.Time,
-		Command:     job.Command,
-		Description: job.Description,
-		IsActive:    job.IsActive,
-		SaveOutput:  job.SaveOutput,
-		Timeout:     job.Timeout,
+		ID:             job.ID,
+		CreatedAt:      job.CreatedAt,
+		Category:       job.Category.Name,
+		Name:           job.Name,
+		Time:           job.Time,
+		Command:        job.Command,
+		Description:    job.Description,
+		IsActive:       job.IsActive,
+		SaveOutput:     job.SaveOutput,
+		Timeout:        job.Timeout,
+		MaxMemory:      job.MaxMemory,
+		MaxProcs:       job.MaxProcs,
+		TmpQuota:       job.TmpQuota,
+		Priority:       job.Priority,
+		OutputEncoding: job.OutputEncoding,
+		ExitCodeMap:    job.ExitCodeMap,
 	}
 }
 
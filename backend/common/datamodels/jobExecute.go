@@ -29,6 +29,7 @@ type JobExecuteInfo struct {
 	ExecuteCtx      context.Context    `json:"-"`              // 执行job的上下文
 	ExceteCancelFun context.CancelFunc `json:"-"`              // 执行执行job的取消函数
 	Status          string             `json:"status"`         // 执行信息的状态：start、timeout、kill、success、error、done
+	TraceID         string             `json:"trace_id"`       // 追踪ID：执行ID-spanID，用于关联日志
 }
 
 // Job执行结果
@@ -41,6 +42,8 @@ type JobExecuteResult struct {
 	StartTime   time.Time       // 启动时间
 	EndTime     time.Time       // 结束时间
 	Status      string          // 执行状态：start、finish、cancel、success、error、timeout
+	IsOverLimit bool            // 是否因为超出资源限制(内存)被杀掉
+	ExitCode    int             // 命令的退出码：未正常退出的是-1
 }
 
 // 任务调度前创建JobExecute
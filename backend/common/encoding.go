@@ -0,0 +1,37 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// 获取字符集：名字参考WHATWG的编码标准，比如：gbk、gb18030、latin1、big5、shift_jis
+// 为空或者是utf-8的返回nil，无需转换
+func GetEncoding(name string) (enc encoding.Encoding, err error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || name == "utf-8" || name == "utf8" {
+		return nil, nil
+	}
+
+	if enc, err = htmlindex.Get(name); err != nil {
+		return nil, fmt.Errorf("不支持的字符集：%s", name)
+	}
+	return enc, nil
+}
+
+// 把指定字符集的内容转换成UTF-8
+func DecodeToUTF8(data []byte, name string) (result []byte, err error) {
+	var (
+		enc encoding.Encoding
+	)
+	if enc, err = GetEncoding(name); err != nil {
+		return data, err
+	}
+	if enc == nil {
+		return data, nil
+	}
+	return enc.NewDecoder().Bytes(data)
+}
@@ -0,0 +1,32 @@
+package common
+
+import "testing"
+
+func TestDecodeToUTF8(t *testing.T) {
+	// "你好"的GBK编码
+	gbkData := []byte{0xc4, 0xe3, 0xba, 0xc3}
+
+	if result, err := DecodeToUTF8(gbkData, "GBK"); err != nil {
+		t.Error(err)
+	} else if string(result) != "你好" {
+		t.Errorf("GBK转换结果不对：%s", result)
+	}
+
+	// latin1: é
+	if result, err := DecodeToUTF8([]byte{0xe9}, "latin1"); err != nil {
+		t.Error(err)
+	} else if string(result) != "é" {
+		t.Errorf("latin1转换结果不对：%s", result)
+	}
+
+	// 为空：不做转换
+	if result, err := DecodeToUTF8([]byte("hello"), ""); err != nil || string(result) != "hello" {
+		t.Errorf("不指定字符集，内容应该不变：%s, %v", result, err)
+	}
+}
+
+func TestGetEncoding_Unknown(t *testing.T) {
+	if _, err := GetEncoding("not-a-charset"); err == nil {
+		t.Error("不支持的字符集应该返回error")
+	}
+}
@@ -3,5 +3,6 @@ package common
 import "errors"
 
 var (
-	LOCK_IS_USING = errors.New("lock is using")
+	LOCK_IS_USING    = errors.New("lock is using")
+	NO_IDLE_EXECUTOR = errors.New("no idle executor")
 )
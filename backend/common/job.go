@@ -3,6 +3,10 @@ package common
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/codelieche/cronjob/backend/common/datamodels"
@@ -54,3 +58,46 @@ func BuildJobExecuteInfo(jobPlan *datamodels.JobSchedulePlan) (jobExecuteInfo *d
 
 	return
 }
+
+// 退出码映射中状态的格式
+var exitStatusPattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_-]{0,31}$`)
+
+// 解析退出码映射
+// 格式是"退出码:状态"，多个用逗号分隔，比如：2:success,3:changed
+// 状态是success的当作执行成功，failed的当作执行失败，其它的当作执行成功，并把执行状态设置为它
+// 状态只能是字母开头的字母、数字、_、-，且不能是EXIT_STATUS_RESERVED中的状态
+func ParseExitCodeMap(value string) (exitCodeMap map[int]string, err error) {
+	var (
+		items  []string
+		code   int
+		status string
+	)
+	exitCodeMap = make(map[int]string)
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return exitCodeMap, nil
+	}
+
+	for _, item := range strings.Split(value, ",") {
+		items = strings.Split(strings.TrimSpace(item), ":")
+		if len(items) != 2 {
+			return nil, fmt.Errorf("退出码映射格式有误：%s，应该是：退出码:状态", item)
+		}
+		if code, err = strconv.Atoi(strings.TrimSpace(items[0])); err != nil || code < 0 || code > 255 {
+			return nil, fmt.Errorf("退出码有误：%s", items[0])
+		}
+		if status = strings.TrimSpace(items[1]); status == "" {
+			return nil, fmt.Errorf("退出码%d的状态为空", code)
+		}
+		if !exitStatusPattern.MatchString(status) {
+			return nil, fmt.Errorf("退出码%d的状态有误：%s，只能是字母开头的字母、数字、_、-，最长32个字符", code, status)
+		}
+		for _, reserved := range EXIT_STATUS_RESERVED {
+			if strings.ToLower(status) == reserved {
+				return nil, fmt.Errorf("退出码%d的状态不能是%s", code, status)
+			}
+		}
+		exitCodeMap[code] = status
+	}
+	return exitCodeMap, nil
+}
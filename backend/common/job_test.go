@@ -0,0 +1,38 @@
+package common
+
+import "testing"
+
+func TestParseExitCodeMap(t *testing.T) {
+	exitCodeMap, err := ParseExitCodeMap(" 2:success, 3:changed ,1:failed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[int]string{1: EXIT_STATUS_FAILED, 2: EXIT_STATUS_SUCCESS, 3: "changed"}
+	if len(exitCodeMap) != len(expected) {
+		t.Errorf("解析结果不对：%v", exitCodeMap)
+	}
+	for code, status := range expected {
+		if exitCodeMap[code] != status {
+			t.Errorf("退出码%d应该是%s，实际是%s", code, status, exitCodeMap[code])
+		}
+	}
+
+	// 为空
+	if exitCodeMap, err = ParseExitCodeMap(""); err != nil || len(exitCodeMap) != 0 {
+		t.Errorf("为空应该返回空的映射：%v, %v", exitCodeMap, err)
+	}
+
+	// 格式有误
+	for _, value := range []string{"2", "a:success", "2:", "300:success", "2:chan ged", "2:<b>", "2:1abc"} {
+		if _, err = ParseExitCodeMap(value); err == nil {
+			t.Errorf("%s应该解析出错", value)
+		}
+	}
+
+	// 执行记录自身的状态不能用
+	for _, status := range []string{"start", "todo", "doing", "doding", "kill", "timeout", "error", "done", "Done"} {
+		if _, err = ParseExitCodeMap("2:" + status); err == nil {
+			t.Errorf("状态%s应该解析出错", status)
+		}
+	}
+}
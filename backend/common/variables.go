@@ -8,6 +8,14 @@ const JOB_EVENT_PUT = 0    // Job PUT事件
 const JOB_EVENT_DELETE = 1 // Job Delete事件
 const JOB_EVENT_KILL = 2   // Job Kill事件
 
+// 退出码映射的状态
+const EXIT_STATUS_SUCCESS = "success" // 当作执行成功
+const EXIT_STATUS_FAILED = "failed"   // 当作执行失败
+
+// 退出码映射中不能使用的状态：执行记录自身流转用到的状态
+// 映射成它们的话，master会把执行记录当作未完成、被杀掉或者超时
+var EXIT_STATUS_RESERVED = []string{"start", "todo", "doing", "doding", "cancel", "kill", "timeout", "error", "finish", "done"}
+
 // ETCD相关变量
 const ETCD_WORKER_DIR = "/crontab/workers/"
 const ETCD_JOBS_DIR = "/crontab/jobs/"
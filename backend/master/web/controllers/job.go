@@ -40,7 +40,9 @@ func (c *JobController) PostCreate(ctx iris.Context) (job *datamodels.Job, err e
 		name                                                string // Job的名字
 		jobCategory                                         *datamodels.Category
 		category, timeStr, command, description, timeoutStr string
-		timeout                                             int
+		maxMemoryStr, maxProcsStr, priorityStr              string
+		outputEncoding, exitCodeMap, tmpQuotaStr            string
+		timeout, maxMemory, maxProcs, priority, tmpQuota    int
 		isActive, saveOutput                                string
 		isActiveValue, saveOutputValue                      bool
 	)
@@ -54,11 +56,41 @@ func (c *JobController) PostCreate(ctx iris.Context) (job *datamodels.Job, err e
 	isActive = strings.ToLower(strings.TrimSpace(ctx.FormValue("is_active")))
 	saveOutput = strings.ToLower(strings.TrimSpace(ctx.FormValue("save_output")))
 	timeoutStr = ctx.FormValueDefault("timeout", "0")
+	maxMemoryStr = ctx.FormValueDefault("max_memory", "0")
+	maxProcsStr = ctx.FormValueDefault("max_procs", "0")
+	tmpQuotaStr = ctx.FormValueDefault("tmp_quota", "0")
+	priorityStr = ctx.FormValueDefault("priority", "0")
+	outputEncoding = strings.TrimSpace(ctx.FormValue("output_encoding"))
+	exitCodeMap = strings.TrimSpace(ctx.FormValue("exit_code_map"))
 
 	if timeout, err = strconv.Atoi(timeoutStr); err != nil {
 		// 传入的超时有误
 		return nil, err
 	}
+	if maxMemory, err = strconv.Atoi(maxMemoryStr); err != nil {
+		// 传入的最大内存有误
+		return nil, err
+	}
+	if maxProcs, err = strconv.Atoi(maxProcsStr); err != nil {
+		// 传入的最大进程数有误
+		return nil, err
+	}
+	if tmpQuota, err = strconv.Atoi(tmpQuotaStr); err != nil {
+		// 传入的临时目录配额有误
+		return nil, err
+	}
+	if priority, err = strconv.Atoi(priorityStr); err != nil {
+		// 传入的优先级有误
+		return nil, err
+	}
+	if _, err = common.GetEncoding(outputEncoding); err != nil {
+		// 传入的字符集有误
+		return nil, err
+	}
+	if _, err = common.ParseExitCodeMap(exitCodeMap); err != nil {
+		// 传入的退出码映射有误
+		return nil, err
+	}
 
 	// 先判断分类是否存在
 	if category == "" {
@@ -89,13 +121,19 @@ func (c *JobController) PostCreate(ctx iris.Context) (job *datamodels.Job, err e
 		EtcdKey:  "",
 		Category: jobCategory,
 		//CategoryID:  0,
-		Name:        name,
-		Time:        timeStr,
-		Command:     command,
-		Description: description,
-		IsActive:    isActiveValue,
-		SaveOutput:  saveOutputValue,
-		Timeout:     timeout,
+		Name:           name,
+		Time:           timeStr,
+		Command:        command,
+		Description:    description,
+		IsActive:       isActiveValue,
+		SaveOutput:     saveOutputValue,
+		Timeout:        timeout,
+		MaxMemory:      maxMemory,
+		MaxProcs:       maxProcs,
+		TmpQuota:       tmpQuota,
+		Priority:       priority,
+		OutputEncoding: outputEncoding,
+		ExitCodeMap:    exitCodeMap,
 	}
 
 	return c.Service.Create(job)
@@ -108,13 +146,17 @@ func (c *JobController) PutBy(id int64, ctx iris.Context) (job *datamodels.Job,
 
 	// 定义变量
 	var (
-		name                                   string // Job的名字
-		jobCategory                            *datamodels.Category
-		time, command, description, timeoutStr string
-		timeout                                int
-		isActive, saveOutput                   string
-		isActiveValue, saveOutputValue         bool
-		updateFields                           map[string]interface{}
+		name                                             string // Job的名字
+		jobCategory                                      *datamodels.Category
+		time, command, description, timeoutStr           string
+		maxMemoryStr, maxProcsStr, priorityStr           string
+		outputEncoding, exitCodeMap, tmpQuotaStr         string
+		timeout, maxMemory, maxProcs, priority, tmpQuota int
+		isActive, saveOutput                             string
+		isActiveValue, saveOutputValue                   bool
+		updateFields                                     map[string]interface{}
+		formValues                                       map[string][]string
+		isExist                                          bool
 	)
 	// 判断job是否存在
 	if job, err = c.Service.GetByID(id); err != nil {
@@ -137,6 +179,14 @@ func (c *JobController) PutBy(id int64, ctx iris.Context) (job *datamodels.Job,
 	isActive = strings.ToLower(strings.TrimSpace(ctx.FormValue("is_active")))
 	saveOutput = strings.ToLower(strings.TrimSpace(ctx.FormValue("save_output")))
 	timeoutStr = ctx.FormValue("timeout")
+	maxMemoryStr = ctx.FormValue("max_memory")
+	maxProcsStr = ctx.FormValue("max_procs")
+	tmpQuotaStr = ctx.FormValue("tmp_quota")
+	priorityStr = ctx.FormValue("priority")
+	outputEncoding = strings.TrimSpace(ctx.FormValue("output_encoding"))
+	exitCodeMap = strings.TrimSpace(ctx.FormValue("exit_code_map"))
+	// 字符集、退出码映射：传了空值表示清空，未传的不修改
+	formValues = ctx.FormValues()
 
 	// 先判断分类是否存在
 	// 分类不做修改
@@ -190,6 +240,60 @@ func (c *JobController) PutBy(id int64, ctx iris.Context) (job *datamodels.Job,
 		}
 	}
 
+	if maxMemoryStr != "" {
+		if maxMemory, err = strconv.Atoi(maxMemoryStr); err != nil {
+			// 传入的最大内存有误
+			return nil, err
+		} else {
+			updateFields["MaxMemory"] = maxMemory
+		}
+	}
+
+	if maxProcsStr != "" {
+		if maxProcs, err = strconv.Atoi(maxProcsStr); err != nil {
+			// 传入的最大进程数有误
+			return nil, err
+		} else {
+			updateFields["MaxProcs"] = maxProcs
+		}
+	}
+
+	if tmpQuotaStr != "" {
+		if tmpQuota, err = strconv.Atoi(tmpQuotaStr); err != nil {
+			// 传入的临时目录配额有误
+			return nil, err
+		} else {
+			updateFields["TmpQuota"] = tmpQuota
+		}
+	}
+
+	if priorityStr != "" {
+		if priority, err = strconv.Atoi(priorityStr); err != nil {
+			// 传入的优先级有误
+			return nil, err
+		} else {
+			updateFields["Priority"] = priority
+		}
+	}
+
+	if _, isExist = formValues["output_encoding"]; isExist && job.OutputEncoding != outputEncoding {
+		if _, err = common.GetEncoding(outputEncoding); err != nil {
+			// 传入的字符集有误
+			return nil, err
+		} else {
+			updateFields["OutputEncoding"] = outputEncoding
+		}
+	}
+
+	if _, isExist = formValues["exit_code_map"]; isExist && job.ExitCodeMap != exitCodeMap {
+		if _, err = common.ParseExitCodeMap(exitCodeMap); err != nil {
+			// 传入的退出码映射有误
+			return nil, err
+		} else {
+			updateFields["ExitCodeMap"] = exitCodeMap
+		}
+	}
+
 	// 对job赋予新的值
 	//log.Println(updateFields)
 	return c.Service.Update(job, updateFields)
@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
@@ -15,7 +16,22 @@ type Worker struct {
 	Scheduler  *Scheduler      // 调度器
 	Categories map[string]bool // 执行计划任务的类型
 	socket     *Socket         // 工作节点连接的Master socket
-	IsActive   bool            // 是否有效
+	IsActive   bool            // 是否有效：socket重连的协程会读取，通过SetIsActive、CheckIsActive访问
+	activeLock *sync.RWMutex   // IsActive的锁
+}
+
+// 设置是否有效
+func (w *Worker) SetIsActive(isActive bool) {
+	w.activeLock.Lock()
+	defer w.activeLock.Unlock()
+	w.IsActive = isActive
+}
+
+// 是否有效
+func (w *Worker) CheckIsActive() bool {
+	w.activeLock.RLock()
+	defer w.activeLock.RUnlock()
+	return w.IsActive
 }
 
 func (w *Worker) Run() {
@@ -41,7 +57,7 @@ func (w *Worker) Run() {
 	go runMonitorWeb()
 
 	// 连接master的socket: 回写各种数据，都是通过socket
-	connectMasterSocket(1)
+	connectMasterSocket(false)
 
 	// 注册worker信息到etcd
 	//go register.keepOnlive()
@@ -54,7 +70,7 @@ func (w *Worker) Run() {
 }
 
 func (w *Worker) Stop() {
-	w.IsActive = false
+	w.SetIsActive(false)
 
 	// 设置调度为停止
 	app.Scheduler.isStoped = true
@@ -66,7 +82,7 @@ func (w *Worker) Stop() {
 	w.socket.Stop()
 
 	// 杀掉正在运行的任务
-	for k, v := range w.Scheduler.jobExecutingTable {
+	for k, v := range w.Scheduler.executingJobs() {
 		log.Println("开始停止：", k)
 		// 执行取消函数
 		v.ExceteCancelFun()
@@ -93,6 +109,7 @@ func NewWorkerApp() *Worker {
 			Scheduler:  scheduler,
 			Categories: make(map[string]bool),
 			IsActive:   true,
+			activeLock: &sync.RWMutex{},
 		}
 	}
 
@@ -2,9 +2,12 @@
 package worker
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"log"
+	"os"
 	"os/exec"
 	"strings"
 	"time"
@@ -28,8 +31,10 @@ func (executor *Executor) ExecuteJob(info *datamodels.JobExecuteInfo, c chan<- *
 		var (
 			jobExecute  *datamodels.JobExecute       // 任务执行
 			jobLockName string                       // job锁的名字
-			cmd         *exec.Cmd                    // shell执行命令
 			output      []byte                       // job执行的输出结果
+			isOverLimit bool                         // 是否超出资源限制
+			isOverQuota bool                         // 是否超出临时目录配额
+			tmpDir      *JobTmpDir                   // 任务的临时目录
 			result      *datamodels.JobExecuteResult // Job执行的结果
 			timeStart   time.Time                    // 开始执行时间
 			//jobLock                *common.JobLock              // 版本1：计划任务的锁
@@ -44,7 +49,7 @@ func (executor *Executor) ExecuteJob(info *datamodels.JobExecuteInfo, c chan<- *
 
 		// log.Println(info.Job)
 		if !info.Job.IsActive {
-			log.Println("当前Job状态是false，无需执行：", info.Job)
+			log.Println("当前Job状态是false，无需执行：", logRedactor.Sprint(info.Job))
 			return
 		}
 
@@ -94,6 +99,7 @@ func (executor *Executor) ExecuteJob(info *datamodels.JobExecuteInfo, c chan<- *
 			return
 		} else {
 			info.JobExecuteID = jobExecute.ID
+			info.TraceID = newTraceID(jobExecute.ID)
 		}
 
 		// log.Println("我是否上锁成功：", jobLock.IsLocked)
@@ -109,7 +115,7 @@ func (executor *Executor) ExecuteJob(info *datamodels.JobExecuteInfo, c chan<- *
 			if needKillJob {
 				//log.Println("需要执行取消函数")
 				// 把当前执行信息的状态设置为kill
-				log.Println("修改状态为kill", info.Job.ID)
+				log.Printf("[%s] 修改状态为kill：%d", info.TraceID, info.Job.ID)
 				info.Status = "kill"
 				info.ExceteCancelFun()
 			} else {
@@ -133,8 +139,8 @@ func (executor *Executor) ExecuteJob(info *datamodels.JobExecuteInfo, c chan<- *
 					//log.Println("执行任务退出")
 					break
 				case <-timer.C:
-					log.Printf("任务超时了，需要执行取消函数：%s-%d,执行ID：%d\n",
-						info.Job.Category, info.Job.ID, info.JobExecuteID)
+					log.Printf("[%s] 任务超时了，需要执行取消函数：%s-%d,执行ID：%d\n",
+						info.TraceID, info.Job.Category, info.Job.ID, info.JobExecuteID)
 					// 把当前执行信息的状态设置为timeout
 					info.Status = "timeout"
 					info.ExceteCancelFun()
@@ -145,42 +151,19 @@ func (executor *Executor) ExecuteJob(info *datamodels.JobExecuteInfo, c chan<- *
 			}()
 		}
 
-		// 传入执行command的上下文
-		cmd = exec.CommandContext(info.ExecuteCtx, "/bin/bash", "-c", info.Job.Command)
+		// 创建任务的临时目录：执行完毕后删除
+		tmpDir = newJobTmpDir(info)
+		defer tmpDir.Remove()
 
-		// 如果需要日志就绑定output
-		if info.Job.SaveOutput {
-			// 执行并捕获输出
-			output, err = cmd.CombinedOutput()
-			//	如果想不保存执行信息，可把推送结果的放到这里来处理：c <- result
-
-		} else {
-			//  log.Println("无需捕获输出结果：依然也需要执行")
-
-			err = cmd.Run()
-			if err != nil {
-				log.Println(info.Job.Name, "执行出错：", err)
-			}
-			output = []byte("Don't save output")
-		}
+		// 传入执行command的上下文，执行命令
+		// 通过环境变量把追踪信息、临时目录传给命令
+		output, isOverLimit, err = runJobCommand(info.ExecuteCtx, info.Job, jobExecuteEnv(info), tmpDir)
+		isOverQuota = tmpDir.IsOverQuota()
 
 		// 无论是否需要saveOutput，都记录执行信息
 		// 任务执行完成后，把执行的结果返回给Scheduler
 		// Scheduler会从executingTable中删除执行记录
-		result = &datamodels.JobExecuteResult{
-			ExecuteID:   info.JobExecuteID, // 把JobExecuteID传递给Result
-			ExecuteInfo: info,
-			IsExecuted:  true, // 有执行到
-			Output:      output,
-			StartTime:   timeStart,
-			EndTime:     time.Now(),
-			Status:      info.Status, // 把状态的结果传递给Result，如果是正常finished的，不对状态做调整
-		}
-
-		// 判断是否有错误
-		if err != nil {
-			result.Error = err.Error()
-		}
+		result = newJobExecuteResult(info, timeStart, output, isOverLimit, isOverQuota, err)
 
 		//log.Println(info.Job.ID, "xxx", result.Status, result.ExecuteID)
 
@@ -197,6 +180,163 @@ func (executor *Executor) ExecuteJob(info *datamodels.JobExecuteInfo, c chan<- *
 	return
 }
 
+// 构造任务执行的结果
+// 对输出和错误脱敏：不把密码等信息发送给master
+// 并根据退出码映射调整执行结果
+func newJobExecuteResult(info *datamodels.JobExecuteInfo, timeStart time.Time, output []byte,
+	isOverLimit bool, isOverQuota bool, err error) (result *datamodels.JobExecuteResult) {
+	result = &datamodels.JobExecuteResult{
+		ExecuteID:   info.JobExecuteID, // 把JobExecuteID传递给Result
+		ExecuteInfo: info,
+		IsExecuted:  true, // 有执行到
+		Output:      logRedactor.Redact(output),
+		StartTime:   timeStart,
+		EndTime:     time.Now(),
+		Status:      info.Status, // 把状态的结果传递给Result，如果是正常finished的，不对状态做调整
+		IsOverLimit: isOverLimit,
+	}
+
+	// 最大进程数设置失败：命令未执行，以保留的退出码退出
+	result.ExitCode = commandExitCode(err)
+	isLimitSetupFailed := info.Job.MaxProcs > 0 && result.ExitCode == limitSetupFailedExitCode
+	if isLimitSetupFailed {
+		result.IsOverLimit = true
+	}
+
+	// 判断是否有错误
+	if isOverQuota {
+		result.Error = fmt.Sprintf("临时目录超出配额(%dMB)，任务被杀掉", info.Job.TmpQuota)
+	} else if isOverLimit {
+		result.Error = fmt.Sprintf("超出内存限制(%dMB)，任务被杀掉", info.Job.MaxMemory)
+	} else if isLimitSetupFailed {
+		result.Error = fmt.Sprintf("设置最大进程数(ulimit -u %d)失败，任务未执行", info.Job.MaxProcs)
+	} else if err != nil {
+		result.Error = logRedactor.RedactString(err.Error())
+	}
+
+	// 根据退出码映射调整执行结果：超出资源限制、资源限制设置失败、超时、被kill的不调整
+	if !result.IsOverLimit && info.Status != "kill" && info.Status != "timeout" {
+		applyExitCodeMap(result, info.Job.ExitCodeMap)
+	}
+	return result
+}
+
+// 执行Job的命令
+// env会追加到worker的环境变量后面，传给命令
+// tmpDir不为空的时候，把临时目录传给命令，并检查临时目录的配额
+// 返回命令的输出，以及是否因为超出内存限制、临时目录配额被杀掉
+func runJobCommand(ctx context.Context, job *datamodels.JobEtcd, env []string, tmpDir *JobTmpDir) (output []byte, isOverLimit bool, err error) {
+	var (
+		cmd     *exec.Cmd      // shell执行命令
+		buffer  *bytes.Buffer  // 捕获输出
+		watcher *memoryWatcher // 内存监控
+	)
+
+	cmd = exec.CommandContext(ctx, "/bin/bash", "-c", wrapCommandWithLimits(job))
+	if tmpDir != nil {
+		env = append(env, tmpDir.Env()...)
+	}
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+
+	// 如果需要日志就绑定output
+	if job.SaveOutput {
+		buffer = &bytes.Buffer{}
+		cmd.Stdout = buffer
+		cmd.Stderr = buffer
+	}
+
+	// 有内存限制、临时目录配额的时候，命令在单独的进程组中执行
+	// 超出限制的时候杀掉整个进程组：只杀掉bash的话，子进程还会继续执行
+	if job.MaxMemory > 0 || job.TmpQuota > 0 {
+		setCommandProcessGroup(cmd)
+	}
+
+	if err = cmd.Start(); err != nil {
+		return nil, false, err
+	}
+
+	if job.MaxMemory > 0 {
+		watcher = newMemoryWatcher(cmd.Process.Pid, job.MaxMemory)
+		go watcher.WatchLoop()
+	}
+	if tmpDir != nil {
+		tmpDir.StartWatch(cmd.Process.Pid)
+	}
+
+	err = cmd.Wait()
+
+	if watcher != nil {
+		isOverLimit = watcher.Stop()
+	}
+	if tmpDir != nil && tmpDir.StopWatch() {
+		isOverLimit = true
+	}
+
+	if job.SaveOutput {
+		output = buffer.Bytes()
+		// 转换成UTF-8：转换出错就保留原始输出
+		if job.OutputEncoding != "" {
+			if decoded, decodeErr := common.DecodeToUTF8(output, job.OutputEncoding); decodeErr != nil {
+				log.Println(job.Name, "转换输出字符集出错：", decodeErr)
+			} else {
+				output = decoded
+			}
+		}
+	} else {
+		//  log.Println("无需捕获输出结果：依然也需要执行")
+		if err != nil {
+			log.Println(job.Name, "执行出错：", err)
+		}
+		output = []byte("Don't save output")
+	}
+	return output, isOverLimit, err
+}
+
+// 获取命令的退出码
+// 正常退出的是0，未正常退出(被信号杀掉、启动失败)的是-1
+func commandExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// 根据Job的退出码映射，调整执行结果
+// success：执行成功；failed：执行失败；其它：执行成功，并把执行状态设置为它
+// 未在映射中的退出码不做调整：0是成功，其它是失败
+func applyExitCodeMap(result *datamodels.JobExecuteResult, value string) {
+	var (
+		exitCodeMap map[int]string
+		status      string
+		isExist     bool
+		err         error
+	)
+	if exitCodeMap, err = common.ParseExitCodeMap(value); err != nil {
+		log.Println("退出码映射有误：", err)
+		return
+	}
+	if status, isExist = exitCodeMap[result.ExitCode]; !isExist {
+		return
+	}
+
+	switch status {
+	case common.EXIT_STATUS_SUCCESS:
+		result.Error = ""
+	case common.EXIT_STATUS_FAILED:
+		if result.Error == "" {
+			result.Error = fmt.Sprintf("exit status %d", result.ExitCode)
+		}
+	default:
+		result.Error = ""
+		result.Status = status
+	}
+}
+
 // Post发送任务执行信息到Master
 // URL：/api/v1/job/execute/create
 // Method: POST
@@ -241,10 +381,29 @@ func (executor *Executor) PostJobExecuteToMaster(jobExecute *datamodels.JobExecu
 	}
 }
 
+// master返回的非2xx响应
+type MasterResponseError struct {
+	StatusCode int    // 响应的状态码
+	Message    string // 响应的内容
+}
+
+func (err *MasterResponseError) Error() string {
+	return err.Message
+}
+
+// 是否是master拒绝了请求(4xx)：重发也不会成功
+func isMasterRejectedError(err error) bool {
+	if responseErr, ok := err.(*MasterResponseError); ok {
+		return responseErr.StatusCode >= 400 && responseErr.StatusCode < 500
+	}
+	return false
+}
+
 // Post发送任务执行信息到Master
 // URL：/api/v1/job/execute/create
 // Method: POST
 // Data: jobExecute
+// master返回非2xx的时候，错误是*MasterResponseError
 func (executor *Executor) PostJobExecuteResultToMaster(result *datamodels.JobExecuteResult) (*datamodels.JobExecuteResult, error) {
 	// 1. 定义变量
 	var (
@@ -278,7 +437,7 @@ func (executor *Executor) PostJobExecuteResultToMaster(result *datamodels.JobExe
 				return result, nil
 			}
 		} else {
-			err = errors.New(string(response.Bytes()))
+			err = &MasterResponseError{StatusCode: response.StatusCode, Message: string(response.Bytes())}
 			return nil, err
 		}
 
@@ -1,6 +1,7 @@
 package worker
 
 import (
+	"context"
 	"log"
 	"testing"
 	"time"
@@ -113,3 +114,55 @@ func TestExecutor_PostCategoryToMaster(t *testing.T) {
 		log.Println(category)
 	}
 }
+
+func TestRunJobCommand_OutputEncoding(t *testing.T) {
+	// 命令输出的是GBK编码的"你好"
+	job := &datamodels.JobEtcd{
+		Name:           "gbk",
+		Command:        `printf '\xc4\xe3\xba\xc3'`,
+		SaveOutput:     true,
+		OutputEncoding: "gbk",
+	}
+
+	if output, _, err := runJobCommand(context.Background(), job, nil, nil); err != nil {
+		t.Error(err)
+	} else if string(output) != "你好" {
+		t.Errorf("输出未转换成UTF-8：%q", output)
+	}
+}
+
+func TestApplyExitCodeMap(t *testing.T) {
+	exitCodeMap := "2:success,3:changed,0:failed"
+	cases := []struct {
+		command     string
+		infoStatus  string // 执行信息的状态：被kill、超时的
+		isOverLimit bool
+		status      string
+		isError     bool
+	}{
+		{"exit 2", "", false, "", false},
+		{"exit 3", "", false, "changed", false},
+		{"exit 0", "", false, "", true},
+		{"exit 1", "", false, "", true}, // 未在映射中：非0是失败
+		// 超时、被kill、超出资源限制的，即使退出码映射成了成功，也依然是失败
+		{"exit 2", "timeout", false, "timeout", true},
+		{"exit 2", "kill", false, "kill", true},
+		{"exit 3", "kill", false, "kill", true},
+		{"exit 2", "", true, "", true},
+	}
+
+	for _, c := range cases {
+		info := &datamodels.JobExecuteInfo{
+			Job:    &datamodels.JobEtcd{Name: "exit", Command: c.command, ExitCodeMap: exitCodeMap},
+			Status: c.infoStatus,
+		}
+		timeStart := time.Now()
+		output, _, err := runJobCommand(context.Background(), info.Job, nil, nil)
+		result := newJobExecuteResult(info, timeStart, output, c.isOverLimit, false, err)
+
+		if result.Status != c.status || (result.Error != "") != c.isError {
+			t.Errorf("%s(status=%q, isOverLimit=%v)的结果不对：status=%q, error=%q",
+				c.command, c.infoStatus, c.isOverLimit, result.Status, result.Error)
+		}
+	}
+}
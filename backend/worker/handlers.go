@@ -24,7 +24,7 @@ func workerInfoHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Para
 	info = make(map[string]interface{})
 	info["app"] = app
 	info["jobPlanTable"] = app.Scheduler.jobPlanTable
-	info["jobExecutingTable"] = app.Scheduler.jobExecutingTable
+	info["jobExecutingTable"] = app.Scheduler.executingJobs()
 	info["jobResultChan"] = len(app.Scheduler.jobResultChan)
 
 	if workerInfoData, err = json.Marshal(info); err != nil {
@@ -56,7 +56,7 @@ func workerStopHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Para
 	go app.Stop()
 
 	info["scheduler.isStoped"] = app.Scheduler.isStoped
-	info["jobExecutingTable"] = app.Scheduler.jobExecutingTable
+	info["jobExecutingTable"] = app.Scheduler.executingJobs()
 	info["jobResultChan"] = len(app.Scheduler.jobResultChan)
 
 	if responseData, err = json.Marshal(info); err != nil {
@@ -12,6 +12,8 @@ var executor *Executor
 var app *Worker
 var register *Register
 var config *common.WorkerConfig
+var jobMetrics *JobMetrics   // 任务执行指标：全局只有一份
+var logRedactor *LogRedactor // 执行结果脱敏
 
 func init() {
 	var (
@@ -19,6 +21,11 @@ func init() {
 	)
 
 	executor = NewExecutor()
+	jobMetrics = NewJobMetrics()
+	if logRedactor, err = NewLogRedactor(common.GetConfig().Worker.RedactPatterns); err != nil {
+		log.Println("脱敏规则有误：", err.Error())
+		os.Exit(1)
+	}
 	app = NewWorkerApp()
 	if register, err = newRegister(); err != nil {
 		log.Println(err.Error())
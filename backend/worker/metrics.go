@@ -0,0 +1,141 @@
+package worker
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/codelieche/cronjob/backend/common/datamodels"
+	"github.com/julienschmidt/httprouter"
+)
+
+// 计划任务执行的监控指标
+// 按分类统计：执行次数、失败次数、执行耗时的直方图
+// 通过worker监控web的/metrics以Prometheus文本格式输出
+
+// 执行耗时直方图的桶(秒)
+var jobDurationBuckets = []float64{0.1, 0.5, 1, 5, 10, 30, 60, 300, 600, 1800, 3600}
+
+// 某个分类的指标
+type categoryMetrics struct {
+	executeCount   int64   // 执行次数
+	failureCount   int64   // 失败次数
+	durationSum    float64 // 执行耗时总和(秒)
+	durationCounts []int64 // 各个桶的计数：与jobDurationBuckets一一对应
+}
+
+// 任务执行指标
+type JobMetrics struct {
+	categories map[string]*categoryMetrics
+	lock       *sync.RWMutex
+}
+
+// 记录一次任务执行结果
+// 未执行的结果(没抢到锁)不记录
+func (m *JobMetrics) Observe(result *datamodels.JobExecuteResult) {
+	var (
+		category string
+		metric   *categoryMetrics
+		isExist  bool
+		duration float64
+	)
+	if !result.IsExecuted || result.ExecuteInfo == nil || result.ExecuteInfo.Job == nil {
+		return
+	}
+
+	category = result.ExecuteInfo.Job.Category
+	duration = result.EndTime.Sub(result.StartTime).Seconds()
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if metric, isExist = m.categories[category]; !isExist {
+		metric = &categoryMetrics{
+			durationCounts: make([]int64, len(jobDurationBuckets)),
+		}
+		m.categories[category] = metric
+	}
+
+	metric.executeCount++
+	if result.Error != "" {
+		metric.failureCount++
+	}
+	metric.durationSum += duration
+	for i, bucket := range jobDurationBuckets {
+		if duration <= bucket {
+			metric.durationCounts[i]++
+		}
+	}
+}
+
+// 以Prometheus文本格式输出指标
+func (m *JobMetrics) WriteTo(buffer *bytes.Buffer) {
+	var (
+		names []string
+	)
+
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	// 分类按名字排序，保证输出稳定
+	for name := range m.categories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buffer.WriteString("# HELP cronjob_worker_job_executions_total 计划任务执行次数\n")
+	buffer.WriteString("# TYPE cronjob_worker_job_executions_total counter\n")
+	for _, name := range names {
+		fmt.Fprintf(buffer, "cronjob_worker_job_executions_total{category=\"%s\"} %d\n",
+			escapeLabelValue(name), m.categories[name].executeCount)
+	}
+
+	buffer.WriteString("# HELP cronjob_worker_job_failures_total 计划任务执行失败次数\n")
+	buffer.WriteString("# TYPE cronjob_worker_job_failures_total counter\n")
+	for _, name := range names {
+		fmt.Fprintf(buffer, "cronjob_worker_job_failures_total{category=\"%s\"} %d\n",
+			escapeLabelValue(name), m.categories[name].failureCount)
+	}
+
+	buffer.WriteString("# HELP cronjob_worker_job_duration_seconds 计划任务执行耗时\n")
+	buffer.WriteString("# TYPE cronjob_worker_job_duration_seconds histogram\n")
+	for _, name := range names {
+		metric := m.categories[name]
+		label := escapeLabelValue(name)
+		for i, bucket := range jobDurationBuckets {
+			fmt.Fprintf(buffer, "cronjob_worker_job_duration_seconds_bucket{category=\"%s\",le=\"%g\"} %d\n",
+				label, bucket, metric.durationCounts[i])
+		}
+		fmt.Fprintf(buffer, "cronjob_worker_job_duration_seconds_bucket{category=\"%s\",le=\"+Inf\"} %d\n", label, metric.executeCount)
+		fmt.Fprintf(buffer, "cronjob_worker_job_duration_seconds_sum{category=\"%s\"} %g\n", label, metric.durationSum)
+		fmt.Fprintf(buffer, "cronjob_worker_job_duration_seconds_count{category=\"%s\"} %d\n", label, metric.executeCount)
+	}
+}
+
+// 标签值转义：Prometheus文本格式只支持\\、\"、\n三种转义
+// 不能用%q，它会输出\t、\x..、\u....，导致抓取失败
+func escapeLabelValue(value string) string {
+	return labelValueReplacer.Replace(value)
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// 监控指标
+// URL: /metrics
+func metricsHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	buffer := &bytes.Buffer{}
+	jobMetrics.WriteTo(buffer)
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(buffer.Bytes())
+}
+
+// 实例化任务执行指标
+func NewJobMetrics() *JobMetrics {
+	return &JobMetrics{
+		categories: make(map[string]*categoryMetrics),
+		lock:       &sync.RWMutex{},
+	}
+}
@@ -0,0 +1,71 @@
+package worker
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codelieche/cronjob/backend/common/datamodels"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	// 1. 准备执行结果：default成功一次、失败一次，未执行的不统计
+	// 监控web使用的是全局的jobMetrics，测试结束后恢复
+	defer func(metrics *JobMetrics) { jobMetrics = metrics }(jobMetrics)
+	jobMetrics = NewJobMetrics()
+	now := time.Now()
+	info := &datamodels.JobExecuteInfo{
+		Job: &datamodels.JobEtcd{ID: 1, Category: "default"},
+	}
+	results := []*datamodels.JobExecuteResult{
+		{ExecuteInfo: info, IsExecuted: true, StartTime: now, EndTime: now.Add(2 * time.Second)},
+		{ExecuteInfo: info, IsExecuted: true, StartTime: now, EndTime: now.Add(time.Minute), Error: "exit status 1"},
+		{ExecuteInfo: info, IsExecuted: false, StartTime: now, EndTime: now},
+	}
+	for _, result := range results {
+		jobMetrics.Observe(result)
+	}
+
+	// 2. 请求/metrics
+	router := newWebMonitorRouter()
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := ioutil.ReadAll(recorder.Body)
+
+	// 3. 检查输出
+	expected := []string{
+		`cronjob_worker_job_executions_total{category="default"} 2`,
+		`cronjob_worker_job_failures_total{category="default"} 1`,
+		`cronjob_worker_job_duration_seconds_bucket{category="default",le="5"} 1`,
+		`cronjob_worker_job_duration_seconds_bucket{category="default",le="60"} 2`,
+		`cronjob_worker_job_duration_seconds_count{category="default"} 2`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(string(body), line) {
+			t.Errorf("指标中缺少：%s\n%s", line, body)
+		}
+	}
+}
+
+// 标签值只转义\\、\"、\n
+func TestJobMetrics_WriteTo_EscapeLabel(t *testing.T) {
+	metrics := NewJobMetrics()
+	now := time.Now()
+	metrics.Observe(&datamodels.JobExecuteResult{
+		ExecuteInfo: &datamodels.JobExecuteInfo{Job: &datamodels.JobEtcd{ID: 1, Category: "a\\b\"c\nd\te中"}},
+		IsExecuted:  true,
+		StartTime:   now,
+		EndTime:     now,
+	})
+
+	buffer := &bytes.Buffer{}
+	metrics.WriteTo(buffer)
+
+	expected := "cronjob_worker_job_executions_total{category=\"a\\\\b\\\"c\\nd\te中\"} 1\n"
+	if !strings.Contains(buffer.String(), expected) {
+		t.Errorf("标签值转义不对，期望：%q\n%s", expected, buffer.String())
+	}
+}
@@ -0,0 +1,91 @@
+package worker
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/codelieche/cronjob/backend/common/datamodels"
+)
+
+// 任务资源限制
+// 1. 最大进程数：在命令前加上ulimit -u，设置失败的话以保留的退出码退出，任务执行失败
+//    ulimit -u限制的是当前用户的进程数，对root用户不生效
+// 2. 最大内存：监控任务进程组的内存占用(RSS)，超过限制就杀掉整个进程组
+
+// 内存检查的间隔
+var memoryCheckInterval = 200 * time.Millisecond
+
+// ulimit设置失败时的退出码：保留的，不参与退出码映射
+// 如果映射成普通的失败码，exit_code_map中配置了1:success的话，没执行的任务也会被当作成功
+const limitSetupFailedExitCode = 253
+
+// 给Job的命令加上ulimit的前缀
+// 比如非root用户设置的值超过了硬限制，ulimit会失败，这时候不执行命令，以limitSetupFailedExitCode退出
+// 注意不能用&&：命令是a; b的时候，b依然会执行
+func wrapCommandWithLimits(job *datamodels.JobEtcd) string {
+	if job.MaxProcs > 0 {
+		return fmt.Sprintf("ulimit -u %d || exit %d; %s", job.MaxProcs, limitSetupFailedExitCode, job.Command)
+	}
+	return job.Command
+}
+
+// 进程组内存监控器
+type memoryWatcher struct {
+	pgid        int           // 进程组ID：就是任务命令的进程ID
+	maxBytes    int64         // 最大内存(字节)
+	isOverLimit bool          // 是否超出了限制
+	doneChan    chan struct{} // 任务执行完毕的channel
+	lock        *sync.Mutex
+}
+
+// 监控循环：超出限制就杀掉进程组
+func (watcher *memoryWatcher) WatchLoop() {
+	var (
+		ticker *time.Ticker
+		used   int64
+		err    error
+	)
+	ticker = time.NewTicker(memoryCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-watcher.doneChan:
+			return
+		case <-ticker.C:
+			if used, err = processGroupMemory(watcher.pgid); err != nil {
+				log.Println("获取进程组内存出错，不再监控：", err)
+				return
+			}
+			if used > watcher.maxBytes {
+				log.Printf("进程组%d内存(%d)超过限制(%d)，需要杀掉", watcher.pgid, used, watcher.maxBytes)
+				watcher.lock.Lock()
+				watcher.isOverLimit = true
+				watcher.lock.Unlock()
+				killProcessGroup(watcher.pgid)
+				return
+			}
+		}
+	}
+}
+
+// 停止监控，返回是否超出了限制
+func (watcher *memoryWatcher) Stop() bool {
+	close(watcher.doneChan)
+	watcher.lock.Lock()
+	defer watcher.lock.Unlock()
+	return watcher.isOverLimit
+}
+
+// 实例化内存监控器
+// maxMemory的单位是MB
+func newMemoryWatcher(pgid int, maxMemory int) *memoryWatcher {
+	return &memoryWatcher{
+		pgid:     pgid,
+		maxBytes: int64(maxMemory) * 1024 * 1024,
+		doneChan: make(chan struct{}),
+		lock:     &sync.Mutex{},
+	}
+}
@@ -0,0 +1,139 @@
+package worker
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// 让命令在新的进程组中执行，方便统计内存和杀掉所有子进程
+func setCommandProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// 杀掉整个进程组
+func killProcessGroup(pgid int) {
+	syscall.Kill(-pgid, syscall.SIGKILL)
+}
+
+// 统计进程组的内存占用(RSS，字节)
+// 从进程组的首进程(pid就是pgid)开始，通过/proc/[pid]/task/[tid]/children找到所有子孙进程，累加pgrp等于pgid的进程的rss
+// 只读取任务自己的进程，不用每次都遍历主机上的所有进程；内核不支持children文件的时候，才遍历/proc
+func processGroupMemory(pgid int) (total int64, err error) {
+	var (
+		pids []int
+		pgrp int
+		rss  int64
+	)
+
+	if _, err = os.Stat(fmt.Sprintf("/proc/%d/task/%d/children", pgid, pgid)); err != nil {
+		if os.IsNotExist(err) {
+			if _, err = os.Stat(fmt.Sprintf("/proc/%d", pgid)); err == nil {
+				// 进程存在，但是内核不支持children文件
+				return scanProcessGroupMemory(pgid)
+			}
+		}
+		return 0, err
+	}
+
+	pids = processTree(pgid)
+	for _, pid := range pids {
+		if pgrp, rss, err = readProcessStat(fmt.Sprintf("/proc/%d/stat", pid)); err != nil || pgrp != pgid {
+			// 进程可能已经退出了，或者到了别的进程组
+			continue
+		}
+		total += rss
+	}
+	return total, nil
+}
+
+// 获取进程及其所有子孙进程的pid
+func processTree(pid int) (pids []int) {
+	var (
+		queue      []int
+		childFiles []string
+		content    []byte
+		childPid   int
+		err        error
+	)
+
+	queue = []int{pid}
+	for len(queue) > 0 {
+		pid, queue = queue[0], queue[1:]
+		pids = append(pids, pid)
+
+		if childFiles, err = filepath.Glob(fmt.Sprintf("/proc/%d/task/*/children", pid)); err != nil {
+			continue
+		}
+		for _, childFile := range childFiles {
+			if content, err = ioutil.ReadFile(childFile); err != nil {
+				continue
+			}
+			for _, field := range strings.Fields(string(content)) {
+				if childPid, err = strconv.Atoi(field); err == nil {
+					queue = append(queue, childPid)
+				}
+			}
+		}
+	}
+	return pids
+}
+
+// 遍历/proc/[pid]/stat，累加pgrp等于pgid的进程的rss
+func scanProcessGroupMemory(pgid int) (total int64, err error) {
+	var (
+		statFiles []string
+		pgrp      int
+		rss       int64
+	)
+
+	if statFiles, err = filepath.Glob("/proc/[0-9]*/stat"); err != nil {
+		return 0, err
+	}
+	if len(statFiles) == 0 {
+		return 0, fmt.Errorf("/proc中未找到进程信息")
+	}
+
+	for _, statFile := range statFiles {
+		if pgrp, rss, err = readProcessStat(statFile); err != nil || pgrp != pgid {
+			// 进程可能已经退出了
+			continue
+		}
+		total += rss
+	}
+	return total, nil
+}
+
+// 读取进程的进程组ID和内存占用(RSS，字节)
+func readProcessStat(statFile string) (pgrp int, rss int64, err error) {
+	var (
+		content []byte
+		fields  []string
+	)
+
+	if content, err = ioutil.ReadFile(statFile); err != nil {
+		return 0, 0, err
+	}
+	// 格式：pid (comm) state ppid pgrp ...，comm中可能有空格，所以从最后一个)开始切分
+	index := strings.LastIndexByte(string(content), ')')
+	if index < 0 {
+		return 0, 0, fmt.Errorf("%s格式有误", statFile)
+	}
+	fields = strings.Fields(string(content[index+1:]))
+	// fields[2]是pgrp，fields[21]是rss(页数)
+	if len(fields) < 22 {
+		return 0, 0, fmt.Errorf("%s格式有误", statFile)
+	}
+	if pgrp, err = strconv.Atoi(fields[2]); err != nil {
+		return 0, 0, err
+	}
+	if rss, err = strconv.ParseInt(fields[21], 10, 64); err != nil {
+		return 0, 0, err
+	}
+	return pgrp, rss * int64(os.Getpagesize()), nil
+}
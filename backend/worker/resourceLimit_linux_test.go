@@ -0,0 +1,110 @@
+package worker
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/codelieche/cronjob/backend/common/datamodels"
+)
+
+func TestRunJobCommand_MaxMemory(t *testing.T) {
+	// 1. 准备job：命令会占用200M以上的内存
+	job := &datamodels.JobEtcd{
+		Name:       "memory-hungry",
+		Command:    "x=$(head -c 200000000 /dev/zero | tr '\\0' a); sleep 5",
+		SaveOutput: true,
+		MaxMemory:  50,
+	}
+
+	// 2. 执行命令
+	_, isOverLimit, err := runJobCommand(context.Background(), job, nil, nil)
+	if err == nil {
+		t.Error("超出内存限制的命令应该被杀掉")
+	}
+	if !isOverLimit {
+		t.Error("isOverLimit应该是true")
+	}
+}
+
+func TestRunJobCommand_UnderLimit(t *testing.T) {
+	job := &datamodels.JobEtcd{
+		Name:       "echo",
+		Command:    "echo hello",
+		SaveOutput: true,
+		MaxMemory:  50,
+		MaxProcs:   1024,
+	}
+
+	output, isOverLimit, err := runJobCommand(context.Background(), job, nil, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	if isOverLimit {
+		t.Error("isOverLimit应该是false")
+	}
+	if string(output) != "hello\n" {
+		t.Errorf("输出结果不对：%s", output)
+	}
+}
+
+// ulimit设置失败的时候，不应该执行命令
+func TestWrapCommandWithLimits_UlimitFailed(t *testing.T) {
+	job := &datamodels.JobEtcd{
+		Name:     "ulimit-failed",
+		Command:  "echo a; echo b",
+		MaxProcs: 1024,
+	}
+
+	// 用同名函数覆盖ulimit，模拟设置失败
+	output, err := exec.Command("/bin/bash", "-c", "ulimit() { return 1; }; "+wrapCommandWithLimits(job)).CombinedOutput()
+	if err == nil {
+		t.Error("ulimit设置失败，任务应该执行失败")
+	}
+	if len(output) > 0 {
+		t.Errorf("ulimit设置失败，不应该执行命令，输出：%s", output)
+	}
+
+	// 退出码映射不能把设置失败当作成功
+	job.ExitCodeMap = "1:success,253:success"
+	info := &datamodels.JobExecuteInfo{Job: job}
+	result := newJobExecuteResult(info, time.Now(), output, false, false, err)
+	if result.ExitCode != limitSetupFailedExitCode {
+		t.Errorf("退出码应该是%d，实际是%d", limitSetupFailedExitCode, result.ExitCode)
+	}
+	if !result.IsOverLimit || result.Error == "" {
+		t.Errorf("ulimit设置失败应该是执行失败：IsOverLimit=%v, Error=%q", result.IsOverLimit, result.Error)
+	}
+}
+
+// 按进程树统计的内存和遍历/proc统计的一致
+func TestProcessGroupMemory(t *testing.T) {
+	cmd := exec.Command("/bin/bash", "-c", "sleep 5 & sleep 5; wait")
+	setCommandProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		killProcessGroup(cmd.Process.Pid)
+		cmd.Wait()
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	pids := processTree(cmd.Process.Pid)
+	if len(pids) != 3 {
+		t.Errorf("进程树应该有3个进程，实际是：%v", pids)
+	}
+
+	total, err := processGroupMemory(cmd.Process.Pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanTotal, err := scanProcessGroupMemory(cmd.Process.Pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total <= 0 || total != scanTotal {
+		t.Errorf("进程组的内存不对：%d, 遍历/proc的是：%d", total, scanTotal)
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+// +build !linux
+
+package worker
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// 非linux系统：暂不支持内存限制、临时目录配额
+func setCommandProcessGroup(cmd *exec.Cmd) {
+}
+
+func killProcessGroup(pgid int) {
+}
+
+func processGroupMemory(pgid int) (total int64, err error) {
+	return 0, errors.New("当前系统不支持内存限制")
+}
@@ -13,5 +13,7 @@ func newWebMonitorRouter() *httprouter.Router {
 	router.POST("/category/add", categoryAddHandler)
 	// 移除worker的category
 	router.DELETE("/category/:name", removeCategoryHandler)
+	// 监控指标
+	router.GET("/metrics", metricsHandler)
 	return router
 }
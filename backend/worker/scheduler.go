@@ -3,6 +3,8 @@ package worker
 import (
 	"fmt"
 	"log"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/codelieche/cronjob/backend/common/datamodels"
@@ -14,22 +16,41 @@ import (
 type Scheduler struct {
 	jobEventChan      chan *datamodels.JobEvent              // etcd任务时间队列
 	jobPlanTable      map[string]*datamodels.JobSchedulePlan // 任务调度计划表
-	jobExecutingTable map[string]*datamodels.JobExecuteInfo  // 任务执行信息表
+	jobExecutingTable map[string]*datamodels.JobExecuteInfo  // 任务执行信息表：调度和处理执行结果的协程都会访问，需要加executingLock
+	executingLock     *sync.RWMutex                          // jobExecutingTable的锁
 	jobResultChan     chan *datamodels.JobExecuteResult      // 任务执行结果队列
 	//logHandler        LogHandler                             // 执行日志处理器
-	isStoped bool // 是否停止调度
+	isStoped            bool                           // 是否停止调度
+	pendingResults      []*datamodels.JobExecuteResult // 未发送成功的执行结果：定时、发送成功后、重连master后补发
+	pendingLock         *sync.Mutex                    // pendingResults的锁
+	isFlushing          bool                           // 是否正在补发：同时只有一个补发
+	maxConcurrency      int                            // 同时执行的任务数上限，0是不限制
+	categoryConcurrency map[string]int                 // 每个分类同时执行的任务数上限
 }
 
+// 最多缓存多少条未发送成功的执行结果
+const maxPendingResults = 1000
+
+// 定时补发执行结果的间隔
+var pendingFlushInterval = 30 * time.Second
+
+// 有任务在等待空闲执行位置时，下次调度的最长间隔
+var scheduleWaitingInterval = 500 * time.Millisecond
+
 // 计算任务调度状态
 // 会尝试执行需要执行的计划任务，并计算jobPlan的下次执行时间
+// 到期的任务按优先级执行：优先级高的先执行，优先级相同的，计划时间早的先执行
+// 没有空闲执行位置的任务，保持到期状态，下次调度再尝试
 // 计算now与所有jobPlan中最近的下次执行的时间的间隔
 // 当间隔大于1分钟的时候，设置其为一分钟
 func (scheduler *Scheduler) TrySchedule() (scheduleAfter time.Duration) {
 	var (
-		jobPlan  *datamodels.JobSchedulePlan // 计划任务执行Plan信息
-		now      time.Time                   // 当前时间
-		nearTime *time.Time                  // 最近一次要执行的计划任务时间
-		err      error                       // error
+		jobPlan   *datamodels.JobSchedulePlan   // 计划任务执行Plan信息
+		duePlans  []*datamodels.JobSchedulePlan // 到期的计划任务
+		now       time.Time                     // 当前时间
+		nearTime  *time.Time                    // 最近一次要执行的计划任务时间
+		isWaiting bool                          // 是否有任务在等待空闲执行位置
+		err       error                         // error
 	)
 	// 1. 遍历所有的job
 
@@ -45,35 +66,92 @@ func (scheduler *Scheduler) TrySchedule() (scheduleAfter time.Duration) {
 		// 2. 过期的任务立即执行
 		// 如果执行计划下次执行的世界早于当前，或者等于当前时间，都需要执行一下这个计划
 		if jobPlan.NextTime.Before(now) || jobPlan.NextTime.Equal(now) {
-			// log.Println("执行计划任务：", jobPlan.Job.Name)
-			// 执行计划任务
-			if err = scheduler.TryRunJob(jobPlan); err != nil {
-				log.Println("执行计划任务出错：", err.Error())
+			duePlans = append(duePlans, jobPlan)
+		}
+	}
+
+	// 按优先级排序后执行
+	sortJobPlansByPriority(duePlans)
+	for _, jobPlan = range duePlans {
+		// log.Println("执行计划任务：", jobPlan.Job.Name)
+		// 执行计划任务
+		if err = scheduler.TryRunJob(jobPlan); err != nil {
+			if err == common.NO_IDLE_EXECUTOR {
+				// 没有空闲的执行位置：不更新NextTime，下次调度再尝试
+				isWaiting = true
+				continue
 			}
-			// 更新NextTime：需要设置新的下次执行时间
-			jobPlan.NextTime = jobPlan.Expression.Next(now)
-		} else {
-			//  log.Println(jobPlan.Job.Name, jobPlan.NextTime)
+			log.Println("执行计划任务出错：", err.Error())
 		}
+		// 更新NextTime：需要设置新的下次执行时间
+		jobPlan.NextTime = jobPlan.Expression.Next(now)
+	}
 
-		// 3. 统计最近要过期的任务还需多久
-		// 当nearTime是空的时候，就赋值当前计划任务的下次执行时间
-		// 当当前jobPlan的下次执行时间，早于nearTime就更新一下nearTime
-		if nearTime == nil || jobPlan.NextTime.Before(*nearTime) {
+	// 3. 统计最近要过期的任务还需多久
+	// 当nearTime是空的时候，就赋值当前计划任务的下次执行时间
+	// 当当前jobPlan的下次执行时间，早于nearTime就更新一下nearTime
+	// 等待执行位置的任务(NextTime早于now)不参与统计
+	for _, jobPlan = range scheduler.jobPlanTable {
+		if jobPlan.NextTime.After(now) && (nearTime == nil || jobPlan.NextTime.Before(*nearTime)) {
 			nearTime = &jobPlan.NextTime
 		}
 	}
 
 	// 4. 返回下次执行TrySchedule的时间
 	// 当前时间与最近一次要执行的任务的时间间隔
-	scheduleAfter = (*nearTime).Sub(now)
+	if nearTime != nil {
+		scheduleAfter = (*nearTime).Sub(now)
+	} else {
+		scheduleAfter = time.Minute
+	}
 	// 下次检查计划任务时间，最多等待1一分钟
 	if scheduleAfter > time.Minute {
 		scheduleAfter = time.Minute
 	}
+	// 有任务在等待执行位置，需要尽快再调度
+	if isWaiting && scheduleAfter > scheduleWaitingInterval {
+		scheduleAfter = scheduleWaitingInterval
+	}
 	return
 }
 
+// 对计划任务按优先级排序
+// 优先级高的在前，优先级相同的，计划时间(NextTime)早的在前
+func sortJobPlansByPriority(jobPlans []*datamodels.JobSchedulePlan) {
+	sort.SliceStable(jobPlans, func(i, j int) bool {
+		if jobPlans[i].Job.Priority != jobPlans[j].Job.Priority {
+			return jobPlans[i].Job.Priority > jobPlans[j].Job.Priority
+		}
+		return jobPlans[i].NextTime.Before(jobPlans[j].NextTime)
+	})
+}
+
+// 是否还有空闲的执行位置
+// 需要同时满足：总的执行数未达到上限，任务所在分类的执行数未达到上限
+// 调用方需要持有executingLock
+func (scheduler *Scheduler) hasIdleExecutor(category string) bool {
+	var (
+		limit     int
+		isExist   bool
+		executing int
+		info      *datamodels.JobExecuteInfo
+	)
+
+	if scheduler.maxConcurrency > 0 && len(scheduler.jobExecutingTable) >= scheduler.maxConcurrency {
+		return false
+	}
+
+	if limit, isExist = scheduler.categoryConcurrency[category]; !isExist || limit <= 0 {
+		return true
+	}
+	for _, info = range scheduler.jobExecutingTable {
+		if info.Job.Category == category {
+			executing++
+		}
+	}
+	return executing < limit
+}
+
 // 调度协程
 func (scheduler *Scheduler) ScheduleLoop() {
 	// 1. 定义变量
@@ -92,6 +170,9 @@ func (scheduler *Scheduler) ScheduleLoop() {
 	// 启动消费执行结果协程：里面会用到logHandler
 	go scheduler.comsumeJobExecuteResultsLoop()
 
+	// 启动定时补发执行结果的协程
+	go scheduler.flushPendingResultsLoop()
+
 	// 启动消费执行日志的协程
 	//go scheduler.logHandler.ConsumeLogsLoop()
 
@@ -120,7 +201,7 @@ func (scheduler *Scheduler) ScheduleLoop() {
 
 	// 遍历所有执行table设置为kill
 	// 手动杀掉所有正在执行的任务
-	for _, info := range scheduler.jobExecutingTable {
+	for _, info := range scheduler.executingJobs() {
 		info.Status = "kill"
 		info.ExceteCancelFun()
 	}
@@ -168,7 +249,7 @@ func (scheduler *Scheduler) handleJobEvent(jobEvent *datamodels.JobEvent) {
 				scheduler.jobPlanTable[jobExecutingKey] = jobSchedulePlan
 			} else {
 				// 如果Job存在那么需要删除
-				log.Println("当前Job状态是flase，无需添加到执行Table中：", jobSchedulePlan.Job)
+				log.Println("当前Job状态是flase，无需添加到执行Table中：", logRedactor.Sprint(jobSchedulePlan.Job))
 				if jobSchedulePlan, isExist = scheduler.jobPlanTable[jobExecutingKey]; isExist {
 					// 存在就删除，不存在就无需操作：
 					log.Printf("需要把%s从jobPlanTable中删除", jobExecutingKey)
@@ -192,7 +273,10 @@ func (scheduler *Scheduler) handleJobEvent(jobEvent *datamodels.JobEvent) {
 		// log.Println(scheduler.jobExecutingTable)
 		//jobExecutingKey = jobEvent.Job.Category + "-" + jobEvent.Job.Name
 		jobExecutingKey = fmt.Sprintf("%s-%d", jobEvent.Job.Category, jobEvent.Job.ID)
-		if jobExecuteInfo, isExist = scheduler.jobExecutingTable[jobExecutingKey]; isExist {
+		scheduler.executingLock.RLock()
+		jobExecuteInfo, isExist = scheduler.jobExecutingTable[jobExecutingKey]
+		scheduler.executingLock.RUnlock()
+		if isExist {
 			// 是的在本work中执行中，那么可以杀掉它
 			log.Println("需要kill job:", jobExecutingKey)
 			// 执行计划任务执行信息中的取消函数
@@ -216,15 +300,24 @@ func (scheduler *Scheduler) TryRunJob(jobPlan *datamodels.JobSchedulePlan) (err
 	)
 	// 如果任务正在执行，跳过本次调度
 	jobExecutingKey = fmt.Sprintf("%s-%d", jobPlan.Job.Category, jobPlan.Job.ID)
+
+	// 判断和保存执行信息需要在同一个锁中：处理执行结果的协程会同时删除
+	scheduler.executingLock.Lock()
 	if jobExecuteInfo, isExecuting = scheduler.jobExecutingTable[jobExecutingKey]; isExecuting {
 		//log.Println("尚未退出，还在执行，跳过！", jobExecutingKey)
+		scheduler.executingLock.Unlock()
 		return
+	} else if !scheduler.hasIdleExecutor(jobPlan.Job.Category) {
+		// 达到了同时执行的任务数上限
+		scheduler.executingLock.Unlock()
+		return common.NO_IDLE_EXECUTOR
 	} else {
 		// 构建执行状态信息
 		jobExecuteInfo = common.BuildJobExecuteInfo(jobPlan)
 		// 保存执行信息
 		//jobExecutingKey = jobPlan.Job.Category + "-" + jobPlan.Job.Name
 		scheduler.jobExecutingTable[jobExecutingKey] = jobExecuteInfo
+		scheduler.executingLock.Unlock()
 		// 执行计划任务
 		executor.ExecuteJob(jobExecuteInfo, scheduler.jobResultChan)
 	}
@@ -234,6 +327,18 @@ func (scheduler *Scheduler) TryRunJob(jobPlan *datamodels.JobSchedulePlan) (err
 	return
 }
 
+// 获取执行中的任务：返回的是副本，可以在锁外遍历
+func (scheduler *Scheduler) executingJobs() map[string]*datamodels.JobExecuteInfo {
+	scheduler.executingLock.RLock()
+	defer scheduler.executingLock.RUnlock()
+
+	jobs := make(map[string]*datamodels.JobExecuteInfo, len(scheduler.jobExecutingTable))
+	for key, info := range scheduler.jobExecutingTable {
+		jobs[key] = info
+	}
+	return jobs
+}
+
 // 回传任务执行结果
 func (scheduler *Scheduler) PushJobExecuteResult(result *datamodels.JobExecuteResult) {
 	scheduler.jobResultChan <- result
@@ -263,7 +368,12 @@ func (scheduler *Scheduler) HandlerJobExecuteResult(result *datamodels.JobExecut
 	// 删掉执行状态
 	jobExecutingKey = fmt.Sprintf("%s-%d", result.ExecuteInfo.Job.Category, result.ExecuteInfo.Job.ID)
 	//delete(scheduler.jobExecutingTable, result.ExecuteInfo.Job.Name)
+	scheduler.executingLock.Lock()
 	delete(scheduler.jobExecutingTable, jobExecutingKey)
+	scheduler.executingLock.Unlock()
+
+	// 记录执行指标
+	jobMetrics.Observe(result)
 
 	// 当前调度的任务，是否执行了
 	// 没抢到执行锁，就不会执行，无需处理结果
@@ -271,8 +381,17 @@ func (scheduler *Scheduler) HandlerJobExecuteResult(result *datamodels.JobExecut
 		// 插入到Mongodb中，并更新执行的log_id
 		if jobExecute, err := executor.PostJobExecuteResultToMaster(result); err != nil {
 			log.Println("保存执行日志结果出错：", err)
+			// master拒绝了的(4xx)，重发也不会成功，直接丢弃
+			// 其它的可能是master断开了、出错了，先缓存起来，之后补发
+			if !isMasterRejectedError(err) {
+				scheduler.addPendingResult(result)
+			}
 		} else {
 			jobExecute = jobExecute
+			// 发送成功了，说明master已恢复，补发缓存的执行结果
+			if scheduler.hasPendingResults() {
+				go scheduler.flushPendingResults()
+			}
 		}
 
 		// 记录日志
@@ -289,10 +408,10 @@ func (scheduler *Scheduler) HandlerJobExecuteResult(result *datamodels.JobExecut
 		// 交给写日志的程序处理【异步去处理】[交给logHandler处理]
 		//scheduler.logHandler.AddLog(jobExecuteLog)
 
-		log.Printf("Job: %s执行完成：%s", jobExecutingKey, result.ExecuteInfo.Job.Command)
+		log.Printf("[%s] Job: %s执行完成：%s", result.ExecuteInfo.TraceID, jobExecutingKey, logRedactor.RedactString(result.ExecuteInfo.Job.Command))
 		// fmt.Println(string(result.Output))
 		if result.Error != "" {
-			log.Printf("%s执行出现了错误：%s\n", jobExecutingKey, result.Error)
+			log.Printf("[%s] %s执行出现了错误：%s\n", result.ExecuteInfo.TraceID, jobExecutingKey, logRedactor.RedactString(result.Error))
 		}
 
 	} else {
@@ -300,6 +419,81 @@ func (scheduler *Scheduler) HandlerJobExecuteResult(result *datamodels.JobExecut
 	}
 }
 
+// 缓存未发送成功的执行结果
+// 超过maxPendingResults就丢弃最早的
+func (scheduler *Scheduler) addPendingResult(result *datamodels.JobExecuteResult) {
+	scheduler.pendingLock.Lock()
+	defer scheduler.pendingLock.Unlock()
+
+	if len(scheduler.pendingResults) >= maxPendingResults {
+		log.Println("未发送的执行结果过多，丢弃最早的一条：", scheduler.pendingResults[0].ExecuteID)
+		scheduler.pendingResults = scheduler.pendingResults[1:]
+	}
+	scheduler.pendingResults = append(scheduler.pendingResults, result)
+}
+
+// 是否有缓存的执行结果
+func (scheduler *Scheduler) hasPendingResults() bool {
+	scheduler.pendingLock.Lock()
+	defer scheduler.pendingLock.Unlock()
+	return len(scheduler.pendingResults) > 0
+}
+
+// 补发缓存的执行结果
+// 定时、发送成功后、重连master成功后调用
+// 依然发送失败的会重新放回缓存，master拒绝了的(4xx)直接丢弃
+func (scheduler *Scheduler) flushPendingResults() {
+	var (
+		results []*datamodels.JobExecuteResult
+		err     error
+	)
+
+	scheduler.pendingLock.Lock()
+	if scheduler.isFlushing {
+		// 已经在补发了
+		scheduler.pendingLock.Unlock()
+		return
+	}
+	scheduler.isFlushing = true
+	results = scheduler.pendingResults
+	scheduler.pendingResults = nil
+	scheduler.pendingLock.Unlock()
+
+	defer func() {
+		scheduler.pendingLock.Lock()
+		scheduler.isFlushing = false
+		scheduler.pendingLock.Unlock()
+	}()
+
+	if len(results) > 0 {
+		log.Printf("开始补发%d条执行结果", len(results))
+	}
+
+	for _, result := range results {
+		if _, err = executor.PostJobExecuteResultToMaster(result); err != nil {
+			if isMasterRejectedError(err) {
+				log.Println("master拒绝了执行结果，丢弃：", result.ExecuteID, err)
+			} else {
+				log.Println("补发执行结果出错：", err)
+				scheduler.addPendingResult(result)
+			}
+		}
+	}
+}
+
+// 定时补发执行结果的循环
+// socket未断开，但是master出错、超时的时候，也能补发
+func (scheduler *Scheduler) flushPendingResultsLoop() {
+	ticker := time.NewTicker(pendingFlushInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if scheduler.hasPendingResults() {
+			scheduler.flushPendingResults()
+		}
+	}
+}
+
 // 消费结果
 
 // 初始化调度器
@@ -316,11 +510,15 @@ func NewScheduler() *Scheduler {
 	//
 	//}
 	scheduler := &Scheduler{
-		jobEventChan:      make(chan *datamodels.JobEvent, 1000),
-		jobPlanTable:      make(map[string]*datamodels.JobSchedulePlan),
-		jobExecutingTable: make(map[string]*datamodels.JobExecuteInfo),
-		jobResultChan:     make(chan *datamodels.JobExecuteResult, 500),
-		isStoped:          false,
+		jobEventChan:        make(chan *datamodels.JobEvent, 1000),
+		jobPlanTable:        make(map[string]*datamodels.JobSchedulePlan),
+		jobExecutingTable:   make(map[string]*datamodels.JobExecuteInfo),
+		executingLock:       &sync.RWMutex{},
+		jobResultChan:       make(chan *datamodels.JobExecuteResult, 500),
+		isStoped:            false,
+		pendingLock:         &sync.Mutex{},
+		maxConcurrency:      common.GetConfig().Worker.MaxConcurrency,
+		categoryConcurrency: common.GetConfig().Worker.CategoryConcurrency,
 		//logHandler:        logHandler,
 	}
 
@@ -0,0 +1,260 @@
+package worker
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codelieche/cronjob/backend/common"
+	"github.com/codelieche/cronjob/backend/common/datamodels"
+	"github.com/gorhill/cronexpr"
+)
+
+// 构造一个已经到期的计划任务
+// IsActive为false：executor不会真正去执行命令
+func newDueJobPlan(id uint, priority int, nextTime time.Time) *datamodels.JobSchedulePlan {
+	return &datamodels.JobSchedulePlan{
+		Job: &datamodels.JobEtcd{
+			ID:       id,
+			Category: "default",
+			Command:  "echo `date`",
+			Priority: priority,
+		},
+		Expression: cronexpr.MustParse("*/5 * * * *"),
+		NextTime:   nextTime,
+	}
+}
+
+func TestSortJobPlansByPriority(t *testing.T) {
+	now := time.Now()
+	jobPlans := []*datamodels.JobSchedulePlan{
+		newDueJobPlan(1, 0, now.Add(-time.Second)),
+		newDueJobPlan(2, 10, now),
+		newDueJobPlan(3, 10, now.Add(-time.Minute)),
+		newDueJobPlan(4, 5, now),
+	}
+
+	sortJobPlansByPriority(jobPlans)
+
+	expected := []uint{3, 2, 4, 1}
+	for i, jobPlan := range jobPlans {
+		if jobPlan.Job.ID != expected[i] {
+			t.Errorf("第%d个应该是%d，实际是%d", i, expected[i], jobPlan.Job.ID)
+		}
+	}
+}
+
+func TestScheduler_TrySchedule_Priority(t *testing.T) {
+	// 1. 同时只能执行1个任务
+	scheduler := NewScheduler()
+	scheduler.maxConcurrency = 1
+
+	now := time.Now()
+	low := newDueJobPlan(1, 0, now.Add(-time.Minute))
+	high := newDueJobPlan(2, 10, now.Add(-time.Second))
+	scheduler.jobPlanTable["default-1"] = low
+	scheduler.jobPlanTable["default-2"] = high
+
+	// 2. 调度：优先级高的先执行
+	scheduleAfter := scheduler.TrySchedule()
+
+	if _, isExecuting := scheduler.jobExecutingTable["default-2"]; !isExecuting {
+		t.Error("优先级高的任务应该先执行")
+	}
+	if _, isExecuting := scheduler.jobExecutingTable["default-1"]; isExecuting {
+		t.Error("优先级低的任务应该等待")
+	}
+	if !low.NextTime.Before(now) {
+		t.Error("等待中的任务不应更新NextTime")
+	}
+	if scheduleAfter > scheduleWaitingInterval {
+		t.Errorf("有任务在等待，下次调度的间隔应该不大于%s，实际是%s", scheduleWaitingInterval, scheduleAfter)
+	}
+
+	// 3. 高优先级的执行完毕，再次调度：低优先级的开始执行
+	scheduler.HandlerJobExecuteResult(&datamodels.JobExecuteResult{ExecuteInfo: &datamodels.JobExecuteInfo{Job: high.Job}})
+	scheduler.TrySchedule()
+	if _, isExecuting := scheduler.jobExecutingTable["default-1"]; !isExecuting {
+		t.Error("有空闲执行位置后，等待中的任务应该执行")
+	}
+}
+
+func TestScheduler_TrySchedule_CategoryConcurrency(t *testing.T) {
+	// 1. file分类同时只能执行1个，database分类不限制
+	scheduler := NewScheduler()
+	scheduler.categoryConcurrency = map[string]int{"file": 1}
+
+	now := time.Now()
+	for i, category := range []string{"file", "file", "database", "database"} {
+		jobPlan := newDueJobPlan(uint(i+1), 0, now.Add(-time.Duration(10-i)*time.Second))
+		jobPlan.Job.Category = category
+		scheduler.jobPlanTable[fmt.Sprintf("%s-%d", category, i+1)] = jobPlan
+	}
+
+	// 2. 调度
+	scheduler.TrySchedule()
+
+	// 3. file分类：计划时间早的先执行，另一个等待；database分类的都执行
+	for key, expected := range map[string]bool{"file-1": true, "file-2": false, "database-3": true, "database-4": true} {
+		if _, isExecuting := scheduler.jobExecutingTable[key]; isExecuting != expected {
+			t.Errorf("%s是否执行中应该是%v", key, expected)
+		}
+	}
+}
+
+// 调度和处理执行结果在不同的协程中同时进行：执行数不超过总的上限和分类的上限
+// 需要用-race执行，检查jobExecutingTable的并发访问
+func TestScheduler_TrySchedule_Concurrent(t *testing.T) {
+	scheduler := NewScheduler()
+	scheduler.maxConcurrency = 3
+	scheduler.categoryConcurrency = map[string]int{"file": 1}
+
+	now := time.Now()
+	for i := 1; i <= 8; i++ {
+		jobPlan := newDueJobPlan(uint(i), i%3, now.Add(-time.Second))
+		if i%2 == 0 {
+			jobPlan.Job.Category = "file"
+		}
+		scheduler.jobPlanTable[fmt.Sprintf("%s-%d", jobPlan.Job.Category, i)] = jobPlan
+	}
+
+	// 1. 处理执行结果的协程：同comsumeJobExecuteResultsLoop，测试结束时退出
+	// 不能直接用comsumeJobExecuteResultsLoop，它不会退出，会影响后面的测试(比如替换了全局的jobMetrics)
+	stopChan := make(chan struct{})
+	stoppedChan := make(chan struct{})
+	go func() {
+		defer close(stoppedChan)
+		for {
+			select {
+			case <-stopChan:
+				return
+			case result := <-scheduler.jobResultChan:
+				scheduler.HandlerJobExecuteResult(result)
+			}
+		}
+	}()
+
+	// 2. 模拟任务执行完毕：不断把执行中的任务的结果推送回去
+	doneChan := make(chan struct{})
+	finishedChan := make(chan struct{})
+	go func() {
+		defer close(finishedChan)
+		for {
+			select {
+			case <-doneChan:
+				return
+			default:
+			}
+			for _, info := range scheduler.executingJobs() {
+				scheduler.PushJobExecuteResult(&datamodels.JobExecuteResult{ExecuteInfo: info})
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	// 3. 不断调度：每次都让所有任务到期
+	for i := 0; i < 500; i++ {
+		for _, jobPlan := range scheduler.jobPlanTable {
+			jobPlan.NextTime = time.Now().Add(-time.Second)
+		}
+		scheduler.TrySchedule()
+
+		executing, fileExecuting := 0, 0
+		for _, info := range scheduler.executingJobs() {
+			executing++
+			if info.Job.Category == "file" {
+				fileExecuting++
+			}
+		}
+		if executing > 3 || fileExecuting > 1 {
+			t.Fatalf("执行数超过了上限：总数%d，file分类%d", executing, fileExecuting)
+		}
+	}
+	// 先停止推送结果，再停止处理结果：否则推送可能阻塞
+	close(doneChan)
+	<-finishedChan
+	close(stopChan)
+	<-stoppedChan
+}
+
+// 执行结果发送失败：4xx的丢弃，其它的缓存起来，下次发送成功后补发
+func TestScheduler_HandlerJobExecuteResult_Pending(t *testing.T) {
+	var (
+		lock        sync.Mutex
+		isHealthy   bool  // master是否正常
+		executeIDs  []int // master保存成功的执行ID
+		newResult   func(executeID uint) *datamodels.JobExecuteResult
+		isCompleted func() bool
+	)
+
+	// 1. 模拟master：ExecuteID为2的拒绝，不正常的时候返回500
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result := &datamodels.JobExecuteResult{}
+		json.NewDecoder(r.Body).Decode(result)
+
+		lock.Lock()
+		defer lock.Unlock()
+		if result.ExecuteID == 2 {
+			http.Error(w, "job execute not found", http.StatusBadRequest)
+			return
+		}
+		if !isHealthy {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		executeIDs = append(executeIDs, int(result.ExecuteID))
+		json.NewEncoder(w).Encode(result)
+	}))
+	defer server.Close()
+
+	masterUrl := common.GetConfig().Worker.MasterUrl
+	defer func() { common.GetConfig().Worker.MasterUrl = masterUrl }()
+	common.GetConfig().Worker.MasterUrl = server.URL
+
+	scheduler := NewScheduler()
+	newResult = func(executeID uint) *datamodels.JobExecuteResult {
+		return &datamodels.JobExecuteResult{
+			ExecuteID:   executeID,
+			ExecuteInfo: &datamodels.JobExecuteInfo{Job: &datamodels.JobEtcd{ID: executeID, Category: "default"}},
+			IsExecuted:  true,
+		}
+	}
+
+	// 2. 被拒绝的：不缓存
+	scheduler.HandlerJobExecuteResult(newResult(2))
+	if scheduler.hasPendingResults() {
+		t.Error("master拒绝的执行结果不应该缓存")
+	}
+
+	// 3. master出错：缓存起来
+	scheduler.HandlerJobExecuteResult(newResult(3))
+	if !scheduler.hasPendingResults() {
+		t.Fatal("发送失败的执行结果应该缓存")
+	}
+
+	// 4. master恢复：发送成功后，补发缓存的
+	lock.Lock()
+	isHealthy = true
+	lock.Unlock()
+	scheduler.HandlerJobExecuteResult(newResult(4))
+
+	isCompleted = func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(executeIDs) == 2
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !isCompleted() && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if len(executeIDs) != 2 || executeIDs[0] != 4 || executeIDs[1] != 3 {
+		t.Errorf("master保存的执行结果不对：%v", executeIDs)
+	}
+}
@@ -195,10 +195,10 @@ func executeCommand(cmdStr string) (success bool, err error) {
 	cmd = exec.Command("/bin/bash", "-c", cmdStr)
 	if outputData, err = cmd.CombinedOutput(); err != nil {
 		// 执行出错
-		log.Printf("执行出错的命令是：%s\n", cmdStr)
+		log.Printf("执行出错的命令是：%s\n", logRedactor.RedactString(cmdStr))
 		return false, err
 	} else {
-		log.Println(string(outputData))
+		log.Println(logRedactor.RedactString(string(outputData)))
 		return true, nil
 	}
 }
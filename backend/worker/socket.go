@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"math/rand"
 	"net/http"
 	"os"
 	"sync"
@@ -47,16 +48,16 @@ func (socket *Socket) ReadeLoop() {
 			// 只对TextMessage类型的消息解包
 			common.UnPacketData(append(tmpBuffer, data...), socket.dataChan)
 		} else {
-			log.Printf("消息为空, 或者消息格式不对：[%d] --> %s", messageType, data)
+			log.Printf("消息为空, 或者消息格式不对：[%d] --> %s", messageType, logRedactor.Redact(data))
 		}
 	}
 	// 连接断开了
 	socket.closeChan <- true
 
-	time.Sleep(time.Second)
+	time.Sleep(reconnectWaitTime)
 	// 需要重新连接
 	log.Println("开始尝试重新连接socket")
-	connectMasterSocket(1)
+	connectMasterSocket(true)
 }
 
 // 把得到的消息，发送给app.messageChan，交给它处理
@@ -69,7 +70,7 @@ func (socket *Socket) consuleMessage(c <-chan []byte) {
 			// 对数据序列化
 			messageEvent := &sockets.MessageEvent{}
 			if err := json.Unmarshal(data, messageEvent); err != nil {
-				msg := fmt.Sprintf("收到消息：%s", data)
+				msg := fmt.Sprintf("收到消息：%s", logRedactor.Redact(data))
 				log.Println(msg)
 			} else {
 				// 对结果进行判断
@@ -79,14 +80,14 @@ func (socket *Socket) consuleMessage(c <-chan []byte) {
 					data := []byte(messageEvent.Data)
 					jobEvent := &datamodels.JobEvent{}
 					if err := json.Unmarshal(data, jobEvent); err != nil {
-						msg := fmt.Sprintf("jobEvent内容有误：%s", messageEvent.Data)
+						msg := fmt.Sprintf("jobEvent内容有误：%s", logRedactor.RedactString(messageEvent.Data))
 						log.Println(msg)
 					} else {
 						// 把事件加入到channel中
 						app.Scheduler.jobEventChan <- jobEvent
 					}
 				default:
-					log.Printf("%s", data)
+					log.Printf("%s", logRedactor.Redact(data))
 					msg := fmt.Sprintf("worker暂时还处理不了类型为%s的事件", messageEvent.Category)
 					log.Println(msg)
 				}
@@ -113,6 +114,9 @@ func (socket *Socket) SendMessage(messageType int, data []byte, needPacket bool)
 		message = data
 	}
 
+	// websocket同时只能有一个写：getJobs、执行结果等消息可能同时发送
+	socket.lock.Lock()
+	defer socket.lock.Unlock()
 	if err = socket.conn.WriteMessage(messageType, message); err != nil {
 		log.Printf("发送消息给%s失败：%s", socket.conn.RemoteAddr(), err)
 		return err
@@ -149,22 +153,45 @@ func (socket *Socket) Stop() {
 	socket.closeChan <- true
 }
 
+// 重连的基础等待时间和最大等待时间
+var reconnectBaseDelay = time.Second
+var reconnectMaxDelay = time.Minute
+
+// socket断开后，等待多久开始重连
+var reconnectWaitTime = time.Second
+
+// 计算第times次连接失败后的等待时间
+// 指数退避：base * 2^(times-1)，最大为reconnectMaxDelay，再加上一半的随机抖动，避免master重启时所有worker同时重连
+func reconnectDelay(times int) time.Duration {
+	delay := reconnectMaxDelay
+	if times < 32 && reconnectBaseDelay<<uint(times-1) < reconnectMaxDelay {
+		delay = reconnectBaseDelay << uint(times-1)
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
 // 连接Master的Socket
-func connectMasterSocket(times int) {
+// isReconnect: 是否是断开后的重连
+// 首次连接失败10次就退出程序；重连会一直重试，成功后重新注册worker信息，并补发未发送成功的执行结果
+func connectMasterSocket(isReconnect bool) {
 	// 1. 定义变量
 	var (
 		config          *common.Config
 		masterSocketUrl string
 		conn            *websocket.Conn
 		response        *http.Response
+		newSocket       *Socket
+		times           int
 		err             error
 	)
 
-	// 2. 获取变量
-	if !app.IsActive {
+	// worker已经停止了，无需连接
+	if !app.CheckIsActive() {
 		log.Println("当前socket状态已经是false了，无需再次重连")
 		return
 	}
+
+	// 2. 获取变量
 	config = common.GetConfig()
 	if masterSocketUrl, err = config.Worker.GetSocketUrl(); err != nil {
 		log.Println("获取socket的url出错：", err.Error())
@@ -172,36 +199,53 @@ func connectMasterSocket(times int) {
 	}
 
 	// 3. 连接socket
-	log.Println(masterSocketUrl)
-	if conn, response, err = websocket.DefaultDialer.Dial(masterSocketUrl, nil); err != nil {
-		log.Printf("第%d次连接socket出错：%s", times, err)
-		if times < 10 {
-			sleepSecond := times * 5
-			log.Printf("%d秒后重试\n", sleepSecond)
-			time.Sleep(time.Second * time.Duration(times*5))
-			connectMasterSocket(times + 1)
+	for times = 1; ; times++ {
+		if !app.CheckIsActive() {
+			log.Println("当前socket状态已经是false了，无需再次重连")
+			return
+		}
+
+		log.Println(masterSocketUrl)
+		if conn, response, err = websocket.DefaultDialer.Dial(masterSocketUrl, nil); err != nil {
+			log.Printf("第%d次连接socket出错：%s", times, err)
+			if !isReconnect && times >= 10 {
+				os.Exit(1)
+			}
+			delay := reconnectDelay(times)
+			log.Printf("%s后重试\n", delay)
+			time.Sleep(delay)
 		} else {
-			os.Exit(1)
+			break
 		}
-	} else {
-		// log.Println(response)
-		response = response
-		// 连接成功
-
-		// 4. 实例化socket
-		socket = &Socket{
-			conn:      conn,
-			lock:      &sync.RWMutex{},
-			IsActive:  true,
-			dataChan:  make(chan []byte, 100),
-			closeChan: make(chan bool, 5),
+	}
+
+	// log.Println(response)
+	response = response
+	// 连接成功
+
+	// 4. 实例化socket
+	// 后面使用局部变量：读取循环里断开重连的时候会替换全局的socket
+	newSocket = &Socket{
+		conn:      conn,
+		lock:      &sync.RWMutex{},
+		IsActive:  true,
+		dataChan:  make(chan []byte, 100),
+		closeChan: make(chan bool, 5),
+	}
+	socket = newSocket
+	app.socket = newSocket
+	// 读取socket的消息
+	go newSocket.ReadeLoop()
+
+	// 5. 重连成功：master可能是重启了，需要重新注册worker信息，并补发缓存的执行结果
+	if isReconnect {
+		if err = register.postWorkerInfoToMaster(); err != nil {
+			log.Println("重连后发送worker信息去master出错：", err)
 		}
-		app.socket = socket
-		// 读取socket的消息
-		go socket.ReadeLoop()
-		// socket发送getEvent的消息
-		time.Sleep(time.Second)
-		go socket.SendMessageEventToMaster("getJobs", `{"category": "getJobs", "data": "0"}`)
+		go app.Scheduler.flushPendingResults()
 	}
 
+	// socket发送getEvent的消息
+	time.Sleep(time.Second)
+	go newSocket.SendMessageEventToMaster("getJobs", `{"category": "getJobs", "data": "0"}`)
 }
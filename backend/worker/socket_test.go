@@ -0,0 +1,127 @@
+package worker
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/codelieche/cronjob/backend/common"
+	"github.com/codelieche/cronjob/backend/common/datamodels"
+	"github.com/gorilla/websocket"
+)
+
+func TestReconnectDelay(t *testing.T) {
+	for times := 1; times <= 40; times++ {
+		delay := reconnectDelay(times)
+		if delay < 0 || delay > reconnectMaxDelay {
+			t.Errorf("第%d次的等待时间不对：%s", times, delay)
+		}
+	}
+	// 第3次：base * 4，抖动后在[2s, 4s]之间
+	if delay := reconnectDelay(3); delay < 2*reconnectBaseDelay || delay > 4*reconnectBaseDelay {
+		t.Errorf("第3次的等待时间不对：%s", delay)
+	}
+}
+
+// 模拟master断开socket：worker应该重连，并补发缓存的执行结果
+func TestConnectMasterSocket_Reconnect(t *testing.T) {
+	var (
+		connectTimes int32
+		resultTimes  int32
+		upgrader     websocket.Upgrader
+		masterConns  []*websocket.Conn // master端的连接：测试结束时关闭
+		connsLock    sync.Mutex
+	)
+
+	// 1. 模拟master
+	mux := http.NewServeMux()
+	mux.HandleFunc("/websocket", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		// 第一次连接直接断开
+		if atomic.AddInt32(&connectTimes, 1) == 1 {
+			conn.Close()
+			return
+		}
+		connsLock.Lock()
+		masterConns = append(masterConns, conn)
+		connsLock.Unlock()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+	echoHandler := func(counter *int32) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if counter != nil {
+				atomic.AddInt32(counter, 1)
+			}
+			data, _ := ioutil.ReadAll(r.Body)
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(data)
+		}
+	}
+	mux.HandleFunc("/api/v1/worker/create", echoHandler(nil))
+	mux.HandleFunc("/api/v1/job/execute/result/create", echoHandler(&resultTimes))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	// 测试结束后恢复全局变量
+	masterUrl, baseDelay, waitTime := common.GetConfig().Worker.MasterUrl, reconnectBaseDelay, reconnectWaitTime
+	defer func() {
+		app.SetIsActive(true)
+		common.GetConfig().Worker.MasterUrl = masterUrl
+		reconnectBaseDelay = baseDelay
+		reconnectWaitTime = waitTime
+	}()
+
+	common.GetConfig().Worker.MasterUrl = server.URL
+	reconnectBaseDelay = 10 * time.Millisecond
+	reconnectWaitTime = 10 * time.Millisecond
+	app.SetIsActive(true)
+
+	// 2. 断开期间未发送成功的执行结果
+	app.Scheduler.addPendingResult(&datamodels.JobExecuteResult{ExecuteID: 1, IsExecuted: true})
+
+	// 3. 连接
+	connectMasterSocket(false)
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if atomic.LoadInt32(&connectTimes) >= 2 && atomic.LoadInt32(&resultTimes) >= 1 && !isFlushing() {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	if atomic.LoadInt32(&connectTimes) < 2 {
+		t.Errorf("socket断开后未重连，连接次数：%d", connectTimes)
+	}
+	if atomic.LoadInt32(&resultTimes) != 1 {
+		t.Errorf("重连后未补发执行结果，补发次数：%d", resultTimes)
+	}
+
+	// 测试结束：不再重连
+	// master关闭连接，等worker的读取循环退出后，再恢复全局变量
+	app.SetIsActive(false)
+	connsLock.Lock()
+	for _, conn := range masterConns {
+		conn.Close()
+	}
+	connsLock.Unlock()
+	time.Sleep(reconnectWaitTime + 200*time.Millisecond)
+}
+
+// 是否正在补发执行结果
+func isFlushing() bool {
+	app.Scheduler.pendingLock.Lock()
+	defer app.Scheduler.pendingLock.Unlock()
+	return app.Scheduler.isFlushing
+}
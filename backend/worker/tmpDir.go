@@ -0,0 +1,133 @@
+package worker
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/codelieche/cronjob/backend/common"
+	"github.com/codelieche/cronjob/backend/common/datamodels"
+)
+
+// 任务的临时目录
+// 每次执行都会创建一个单独的临时目录，通过环境变量TMPDIR、CRONJOB_TMP_DIR传给命令
+// 执行完毕后(包括panic)删除；设置了配额的，超出后会杀掉任务的整个进程组
+
+// 临时目录大小检查的间隔
+var tmpDirCheckInterval = time.Second
+
+type JobTmpDir struct {
+	Path        string        // 临时目录的路径：创建失败的时候为空
+	quota       int64         // 配额(字节)，0是不限制
+	isOverQuota bool          // 是否超出了配额
+	doneChan    chan struct{} // 停止检查的channel
+	lock        *sync.Mutex
+}
+
+// 传给命令的环境变量
+func (tmpDir *JobTmpDir) Env() []string {
+	if tmpDir.Path == "" {
+		return nil
+	}
+	return []string{
+		fmt.Sprintf("TMPDIR=%s", tmpDir.Path),
+		fmt.Sprintf("CRONJOB_TMP_DIR=%s", tmpDir.Path),
+	}
+}
+
+// 开始检查临时目录的大小：超出配额就杀掉进程组
+// pgid是任务命令的进程ID：有配额的时候，命令在单独的进程组中执行
+func (tmpDir *JobTmpDir) StartWatch(pgid int) {
+	if tmpDir.Path == "" || tmpDir.quota <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(tmpDirCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-tmpDir.doneChan:
+				return
+			case <-ticker.C:
+				if size := dirSize(tmpDir.Path); size > tmpDir.quota {
+					log.Printf("临时目录%s大小(%d)超过配额(%d)，需要杀掉进程组%d", tmpDir.Path, size, tmpDir.quota, pgid)
+					tmpDir.lock.Lock()
+					tmpDir.isOverQuota = true
+					tmpDir.lock.Unlock()
+					killProcessGroup(pgid)
+					return
+				}
+			}
+		}
+	}()
+}
+
+// 停止检查，返回是否超出了配额
+func (tmpDir *JobTmpDir) StopWatch() bool {
+	close(tmpDir.doneChan)
+	return tmpDir.IsOverQuota()
+}
+
+// 是否超出了配额
+func (tmpDir *JobTmpDir) IsOverQuota() bool {
+	tmpDir.lock.Lock()
+	defer tmpDir.lock.Unlock()
+	return tmpDir.isOverQuota
+}
+
+// 删除临时目录
+func (tmpDir *JobTmpDir) Remove() {
+	if tmpDir.Path == "" {
+		return
+	}
+	if err := os.RemoveAll(tmpDir.Path); err != nil {
+		log.Println("删除临时目录出错：", err)
+	}
+}
+
+// 统计目录的大小
+func dirSize(dir string) (size int64) {
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// 创建任务的临时目录
+// 在worker配置的tmp_dir中创建，未配置的使用系统的临时目录
+// 创建失败的话，任务依然执行，只是没有单独的临时目录
+func newJobTmpDir(info *datamodels.JobExecuteInfo) (tmpDir *JobTmpDir) {
+	var (
+		baseDir string
+		err     error
+	)
+
+	tmpDir = &JobTmpDir{
+		quota:    int64(info.Job.TmpQuota) * 1024 * 1024,
+		doneChan: make(chan struct{}),
+		lock:     &sync.Mutex{},
+	}
+
+	if baseDir = common.GetConfig().Worker.TmpDir; baseDir == "" {
+		baseDir = filepath.Join(os.TempDir(), "cronjob")
+	}
+	if err = os.MkdirAll(baseDir, 0755); err != nil {
+		log.Println("创建临时目录出错：", err)
+		return tmpDir
+	}
+
+	prefix := fmt.Sprintf("%s-%d-%d-", info.Job.Category, info.Job.ID, info.JobExecuteID)
+	if tmpDir.Path, err = ioutil.TempDir(baseDir, prefix); err != nil {
+		log.Println("创建临时目录出错：", err)
+	}
+	return tmpDir
+}
@@ -0,0 +1,91 @@
+package worker
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codelieche/cronjob/backend/common/datamodels"
+)
+
+// 命令中可以使用临时目录，执行完毕后临时目录被删除
+func TestJobTmpDir_Remove(t *testing.T) {
+	info := &datamodels.JobExecuteInfo{
+		Job: &datamodels.JobEtcd{
+			ID:         5,
+			Name:       "tmp",
+			Category:   "default",
+			Command:    `echo hello > "$CRONJOB_TMP_DIR/a.txt" && cat "$TMPDIR/a.txt"`,
+			SaveOutput: true,
+		},
+		JobExecuteID: 16,
+	}
+
+	tmpDir := newJobTmpDir(info)
+	if tmpDir.Path == "" {
+		t.Fatal("创建临时目录失败")
+	}
+	if !strings.Contains(tmpDir.Path, "default-5-16-") {
+		t.Errorf("临时目录的名字不对：%s", tmpDir.Path)
+	}
+
+	output, _, err := runJobCommand(context.Background(), info.Job, nil, tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(output) != "hello\n" {
+		t.Errorf("命令的输出不对：%q", output)
+	}
+
+	tmpDir.Remove()
+	if _, err = os.Stat(tmpDir.Path); !os.IsNotExist(err) {
+		t.Errorf("临时目录%s应该已经被删除", tmpDir.Path)
+	}
+}
+
+// 临时目录超出配额，任务的整个进程组被杀掉
+// 写文件的是子shell，不是最后一个命令：只杀掉bash的话，子shell会继续写
+// 而且捕获输出时，子shell不退出cmd.Wait就不会返回
+func TestJobTmpDir_Quota(t *testing.T) {
+	tmpDirCheckInterval = 100 * time.Millisecond
+	defer func() { tmpDirCheckInterval = time.Second }()
+
+	info := &datamodels.JobExecuteInfo{
+		Job: &datamodels.JobEtcd{
+			ID:         6,
+			Name:       "quota",
+			Category:   "default",
+			Command:    `(for i in $(seq 1 50); do head -c 1048576 /dev/zero >> "$TMPDIR/a.bin"; sleep 0.1; done); echo finished`,
+			SaveOutput: true,
+			TmpQuota:   1,
+		},
+		JobExecuteID: 17,
+	}
+
+	tmpDir := newJobTmpDir(info)
+	defer tmpDir.Remove()
+
+	timeStart := time.Now()
+	output, isOverLimit, err := runJobCommand(context.Background(), info.Job, nil, tmpDir)
+	if !tmpDir.IsOverQuota() || !isOverLimit {
+		t.Error("应该超出临时目录配额")
+	}
+	if err == nil {
+		t.Error("超出配额的任务应该被杀掉")
+	}
+	if strings.Contains(string(output), "finished") {
+		t.Error("超出配额后任务不应该继续执行")
+	}
+	if time.Since(timeStart) > 5*time.Second {
+		t.Error("超出配额后没有及时杀掉任务")
+	}
+
+	// 杀掉后临时目录不再增长
+	size := dirSize(tmpDir.Path)
+	time.Sleep(300 * time.Millisecond)
+	if dirSize(tmpDir.Path) != size {
+		t.Error("超出配额后还在写临时目录")
+	}
+}
@@ -0,0 +1,34 @@
+package worker
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/codelieche/cronjob/backend/common/datamodels"
+)
+
+// 任务执行的追踪信息
+// 追踪ID：执行ID-spanID，会写到worker的日志中，并通过环境变量传给执行的命令
+// 命令中可以通过$CRONJOB_TRACE_ID等输出，方便和其它系统的日志关联
+
+// 生成追踪ID
+func newTraceID(executeID uint) string {
+	span := make([]byte, 8)
+	if _, err := rand.Read(span); err != nil {
+		return fmt.Sprintf("%d", executeID)
+	}
+	return fmt.Sprintf("%d-%s", executeID, hex.EncodeToString(span))
+}
+
+// 传给执行命令的环境变量
+func jobExecuteEnv(info *datamodels.JobExecuteInfo) []string {
+	return []string{
+		fmt.Sprintf("CRONJOB_TRACE_ID=%s", info.TraceID),
+		fmt.Sprintf("CRONJOB_EXECUTE_ID=%d", info.JobExecuteID),
+		fmt.Sprintf("CRONJOB_JOB_ID=%d", info.Job.ID),
+		fmt.Sprintf("CRONJOB_JOB_NAME=%s", info.Job.Name),
+		fmt.Sprintf("CRONJOB_CATEGORY=%s", info.Job.Category),
+		fmt.Sprintf("CRONJOB_WORKER=%s", register.Info.Name),
+	}
+}
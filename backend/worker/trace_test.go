@@ -0,0 +1,97 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/codelieche/cronjob/backend/common"
+	"github.com/codelieche/cronjob/backend/common/datamodels"
+)
+
+func TestNewTraceID(t *testing.T) {
+	traceID := newTraceID(12)
+	if !regexp.MustCompile(`^12-[0-9a-f]{16}$`).MatchString(traceID) {
+		t.Errorf("追踪ID格式不对：%s", traceID)
+	}
+	if traceID == newTraceID(12) {
+		t.Error("每次生成的追踪ID应该不同")
+	}
+}
+
+// 追踪信息应该通过环境变量传给执行的命令
+func TestRunJobCommand_TraceEnv(t *testing.T) {
+	info := &datamodels.JobExecuteInfo{
+		Job: &datamodels.JobEtcd{
+			ID:         3,
+			Name:       "trace",
+			Category:   "default",
+			Command:    `echo "$CRONJOB_TRACE_ID $CRONJOB_EXECUTE_ID $CRONJOB_JOB_ID $CRONJOB_CATEGORY"`,
+			SaveOutput: true,
+		},
+		JobExecuteID: 12,
+	}
+	info.TraceID = newTraceID(info.JobExecuteID)
+
+	output, _, err := runJobCommand(context.Background(), info.Job, jobExecuteEnv(info), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := fmt.Sprintf("%s 12 3 default\n", info.TraceID)
+	if string(output) != expected {
+		t.Errorf("命令中获取的环境变量不对：%q, 期望是：%q", output, expected)
+	}
+}
+
+// 处理执行结果的日志中应该有追踪ID
+func TestScheduler_HandlerJobExecuteResult_TraceLog(t *testing.T) {
+	// 1. 模拟master：原样返回执行结果
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	masterUrl := common.GetConfig().Worker.MasterUrl
+	defer func() { common.GetConfig().Worker.MasterUrl = masterUrl }()
+	common.GetConfig().Worker.MasterUrl = server.URL
+
+	// 2. 捕获日志
+	buffer := &bytes.Buffer{}
+	log.SetOutput(buffer)
+	defer log.SetOutput(os.Stderr)
+
+	info := &datamodels.JobExecuteInfo{
+		Job: &datamodels.JobEtcd{
+			ID:       3,
+			Name:     "trace",
+			Category: "default",
+			Command:  "exit 1",
+		},
+		JobExecuteID: 12,
+	}
+	info.TraceID = newTraceID(info.JobExecuteID)
+	NewScheduler().HandlerJobExecuteResult(&datamodels.JobExecuteResult{
+		ExecuteID:   info.JobExecuteID,
+		ExecuteInfo: info,
+		IsExecuted:  true,
+		Error:       "exit status 1",
+	})
+
+	// 3. 执行完成、执行出错的日志都以追踪ID开头
+	prefix := fmt.Sprintf("[%s] ", info.TraceID)
+	for _, expected := range []string{prefix + "Job: default-3执行完成", prefix + "default-3执行出现了错误"} {
+		if !strings.Contains(buffer.String(), expected) {
+			t.Errorf("日志中没有：%q，日志：%s", expected, buffer.String())
+		}
+	}
+}
@@ -69,7 +69,7 @@ func (watch *WatchJobsHandler) HandlerWatchChan(watchChan clientv3.WatchChan) {
 				// 反序列化，推送给调度协程
 				if job, err = common.UnpackByteToJob(watchEvent.Kv.Value); err != nil {
 					//log.Println(err.Error())
-					log.Println(string(watchEvent.Kv.Value))
+					log.Println(logRedactor.RedactString(string(watchEvent.Kv.Value)))
 					continue
 				} else {
 					//log.Println("监听到新的job：", job)
@@ -91,10 +91,10 @@ func (watch *WatchJobsHandler) HandlerWatchChan(watchChan clientv3.WatchChan) {
 				// 反序列化，推送给调度协程
 				if job, err = common.UnpackByteToJob(watchEvent.PrevKv.Value); err != nil {
 					//log.Println(err.Error())
-					log.Println(string(watchEvent.Kv.Value))
+					log.Println(logRedactor.RedactString(string(watchEvent.Kv.Value)))
 					continue
 				} else {
-					log.Println("监听到删除job：", job)
+					log.Println("监听到删除job：", logRedactor.Sprint(job))
 
 					// 添加jobEvent
 					jobEvent = &datamodels.JobEvent{